@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gortloveslinux/lisp/lisp"
+)
+
+// runFmt formats the files named by args, or standard input when there are
+// none, and returns the process exit status.
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("lisp fmt", flag.ContinueOnError)
+	write := fs.Bool("w", false, "write the result to the file instead of standard output")
+	diff := fs.Bool("d", false, "print a diff instead of the formatted source")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: lisp fmt [-w] [-d] [file.lisp ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "lisp fmt: cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := fmtFile("<stdin>", src, false, *diff); err != nil {
+			report("<stdin>", err)
+			return 1
+		}
+		return 0
+	}
+	status := 0
+	for _, name := range fs.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if err := fmtFile(name, src, *write, *diff); err != nil {
+			report(name, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// fmtFile formats src, read from name, and then writes it back to the file,
+// prints a diff, or prints it, as asked.
+func fmtFile(name string, src []byte, write, diff bool) error {
+	out, err := lisp.Format(src)
+	if err != nil {
+		return err
+	}
+	if diff {
+		if !bytes.Equal(src, out) {
+			fmt.Print(unifiedDiff(name, string(src), string(out)))
+		}
+	}
+	if write {
+		if bytes.Equal(src, out) {
+			return nil
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(name, out, fi.Mode().Perm())
+	}
+	if !diff {
+		_, err = os.Stdout.Write(out)
+	}
+	return err
+}
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// unifiedDiff returns the changes from a to b, both the contents of the
+// file name, in unified diff format.
+func unifiedDiff(name, a, b string) string {
+	ops := editScript(splitLines(a), splitLines(b))
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+	// Lines of a and b before ops[k]
+	ai, bi := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k][0] == ' ' {
+			ai, bi, k = ai+1, bi+1, k+1
+			continue
+		}
+		// A hunk runs from the context before this change to the context
+		// after the last change within twice the context of the one before
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for n := k; n < len(ops) && n-end <= 2*diffContext; n++ {
+			if ops[n][0] != ' ' {
+				end = n + 1
+			}
+		}
+		end += diffContext
+		if end > len(ops) {
+			end = len(ops)
+		}
+		as, bs := ai-(k-start), bi-(k-start)
+		var an, bn int
+		for _, op := range ops[start:end] {
+			if op[0] != '+' {
+				an++
+			}
+			if op[0] != '-' {
+				bn++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(as, an), hunkRange(bs, bn))
+		for _, op := range ops[start:end] {
+			out.WriteString(op + "\n")
+		}
+		for _, op := range ops[k:end] {
+			if op[0] != '+' {
+				ai++
+			}
+			if op[0] != '-' {
+				bi++
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// editScript returns the shortest edit script turning x into y, one line
+// per entry prefixed by ' ', '-' or '+'. It uses Myers' O(ND) algorithm,
+// which keeps for each number of edits d the furthest point reached along
+// each diagonal, so it needs time and space in proportion to how much the
+// lines differ rather than to the product of their lengths.
+func editScript(x, y []string) []string {
+	n, m := len(x), len(y)
+	max := n + m
+	// v[max+k] is the furthest line of x reached on diagonal k, where
+	// k = i - j; trace[d] holds diagonals -d to d after d edits
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				i = v[max+k+1] // Insert y[j-1]
+			} else {
+				i = v[max+k-1] + 1 // Delete x[i-1]
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			v[max+k] = i
+			if i >= n && j >= m {
+				done = true
+				break
+			}
+		}
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+		if done {
+			break
+		}
+	}
+	// Walk back from the end, collecting the script in reverse
+	var ops []string
+	i, j := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1] // prev[k+d-1] is diagonal k
+		k := i - j
+		var pk int
+		if k == -d || k != d && prev[k-1+d-1] < prev[k+1+d-1] {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		pi := prev[pk+d-1]
+		pj := pi - pk
+		mi := pi // Where the edit left off, before the common lines after it
+		if pk == k-1 {
+			mi++
+		}
+		for ; i > mi; i, j = i-1, j-1 {
+			ops = append(ops, " "+x[i-1])
+		}
+		if pk == k+1 {
+			ops = append(ops, "+"+y[j-1])
+		} else {
+			ops = append(ops, "-"+x[i-1])
+		}
+		i, j = pi, pj
+	}
+	for ; i > 0; i-- {
+		ops = append(ops, " "+x[i-1])
+	}
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
+
+// hunkRange returns the range of n lines from the 0-based line start as a
+// unified diff writes it.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// splitLines splits s into lines without their newlines.
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"(define (f x)\n(+ x 1))\n(define y 2)\n\n\n(display\n  y)\n",
+			"(define (f x)\n  (+ x 1))\n(define y 2)\n\n(display y)\n", `--- f.lisp.orig
++++ f.lisp
+@@ -1,7 +1,5 @@
+ (define (f x)
+-(+ x 1))
++  (+ x 1))
+ (define y 2)
+ 
+-
+-(display
+-  y)
++(display y)
+`},
+		// Changes far enough apart are in separate hunks
+		{"a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n", "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n", `--- f.lisp.orig
++++ f.lisp
+@@ -1,4 +1,4 @@
+-a
++A
+ 1
+ 2
+ 3
+@@ -7,4 +7,4 @@
+ 6
+ 7
+ 8
+-b
++B
+`},
+		{"", "a\n", "--- f.lisp.orig\n+++ f.lisp\n@@ -0,0 +1 @@\n+a\n"},
+		{"a\n", "", "--- f.lisp.orig\n+++ f.lisp\n@@ -1 +0,0 @@\n-a\n"},
+		{"a\nb\n", "a\nb\n", "--- f.lisp.orig\n+++ f.lisp\n"},
+	}
+	for _, tst := range tests {
+		if got := unifiedDiff("f.lisp", tst.a, tst.b); got != tst.expected {
+			t.Errorf("For %q and %q\nExpected:\n%s\nGot:\n%s", tst.a, tst.b, tst.expected, got)
+		}
+	}
+}
+
+// lcsLen returns the length of the longest common subsequence of x and y.
+func lcsLen(x, y []string) int {
+	prev := make([]int, len(y)+1)
+	for i := range x {
+		cur := make([]int, len(y)+1)
+		for j := range y {
+			switch {
+			case x[i] == y[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(y)]
+}
+
+func TestEditScript(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		s := make([]string, r.Intn(12))
+		for i := range s {
+			s[i] = string(rune('a' + r.Intn(3)))
+		}
+		return s
+	}
+	for n := 0; n < 1000; n++ {
+		x, y := lines(), lines()
+		var a, b []string
+		edits := 0
+		for _, op := range editScript(x, y) {
+			if op[0] != '+' {
+				a = append(a, op[1:])
+			}
+			if op[0] != '-' {
+				b = append(b, op[1:])
+			}
+			if op[0] != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(a, "") != strings.Join(x, "") || strings.Join(b, "") != strings.Join(y, "") {
+			t.Fatalf("The script for %v and %v gives %v and %v", x, y, a, b)
+		}
+		if min := len(x) + len(y) - 2*lcsLen(x, y); edits != min {
+			t.Fatalf("The script for %v and %v has %d edits, not %d", x, y, edits, min)
+		}
+	}
+}
+
+func TestEditScriptLarge(t *testing.T) {
+	// A table of every pair of lines would not fit in memory
+	var x, y []string
+	for i := 0; i < 100000; i++ {
+		x = append(x, fmt.Sprint(i))
+		if i%10000 != 0 {
+			y = append(y, fmt.Sprint(i))
+		}
+	}
+	if ops := editScript(x, y); len(ops) != len(x) {
+		t.Errorf("Expected %d lines, got %d", len(x), len(ops))
+	}
+}
@@ -2,9 +2,9 @@ module gortloveslinux/lisp
 
 go 1.19
 
-require golang.org/x/tools v0.1.12
-
 require (
-	golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
-	golang.org/x/sys v0.0.0-20221010170243-090e33056c14 // indirect
+	golang.org/x/sys v0.0.0-20221010170243-090e33056c14
+	golang.org/x/tools v0.1.12
 )
+
+require golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+
+	"gortloveslinux/lisp/lisp"
+)
+
+// runHighlight writes the file named by args, or standard input, to
+// standard output as highlighted HTML, and returns the process exit status.
+func runHighlight(args []string) int {
+	fs := flag.NewFlagSet("lisp highlight", flag.ContinueOnError)
+	fragment := fs.Bool("fragment", false, "write only the <pre> element, without a page or style sheet")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: lisp highlight [-fragment] [file.lisp]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	var (
+		src  []byte
+		err  error
+		name = "<stdin>"
+	)
+	if fs.NArg() == 0 || fs.Arg(0) == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		name = fs.Arg(0)
+		src, err = os.ReadFile(name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !*fragment {
+		fmt.Printf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+			html.EscapeString(name), lisp.HighlightStyle)
+	}
+	if err := lisp.Highlight(os.Stdout, src); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !*fragment {
+		fmt.Print("</body>\n</html>\n")
+	}
+	return 0
+}
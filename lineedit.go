@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gortloveslinux/lisp/lisp"
+)
+
+// maxHistory is the number of lines of history kept.
+const maxHistory = 1000
+
+// errInterrupt is returned by readLine when the user types Ctrl-C.
+var errInterrupt = errors.New("interrupt")
+
+// The keys readLine handles. Escape sequences are read as runes beyond
+// unicode.MaxRune.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+const (
+	keyUnknown = unicode.MaxRune + 1 + iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+// A lineEditor reads lines from a terminal with Emacs style editing keys,
+// history, reverse search, completion and highlighting of the parenthesis
+// matching the one before the cursor. When its input or output is not a
+// terminal it reads plain lines.
+type lineEditor struct {
+	fd       int
+	r        *bufio.Reader
+	w        io.Writer
+	tty      bool
+	history  []string
+	histFile string // Where history is saved, or "" for nowhere
+	// complete returns the completions of a prefix of a symbol
+	complete func(prefix string) []string
+
+	// The line being edited
+	buf []rune
+	pos int
+}
+
+// newLineEditor returns a line editor reading from in through r and
+// writing to out, with the history saved in histFile. r may be shared with
+// others reading in, such as the interpreter's read-line, so that input
+// read ahead is not lost to them.
+func newLineEditor(in *os.File, r *bufio.Reader, out *os.File, histFile string, complete func(string) []string) *lineEditor {
+	e := &lineEditor{
+		fd:       int(in.Fd()),
+		r:        r,
+		w:        out,
+		tty:      isTerminal(int(in.Fd())) && isTerminal(int(out.Fd())) && os.Getenv("TERM") != "dumb",
+		histFile: histFile,
+		complete: complete,
+	}
+	if e.tty {
+		e.loadHistory()
+	}
+	return e
+}
+
+// historyFile returns the file REPL history is kept in: $LISP_HISTORY, or
+// .lisp_history in the home directory.
+func historyFile() string {
+	if f, ok := os.LookupEnv("LISP_HISTORY"); ok {
+		return f
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lisp_history")
+}
+
+// loadHistory reads the history file, trimming it to the last maxHistory
+// lines.
+func (e *lineEditor) loadHistory() {
+	if e.histFile == "" {
+		return
+	}
+	b, err := os.ReadFile(e.histFile)
+	if err != nil {
+		return
+	}
+	e.history = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		os.WriteFile(e.histFile, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+	}
+}
+
+// addHistory adds line to the history and appends it to the history file,
+// unless it is blank or repeats the previous line.
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// readLine reads a line after writing prompt. It returns io.EOF if Ctrl-D is
+// typed on an empty line and errInterrupt if Ctrl-C is typed.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	if !e.tty {
+		fmt.Fprint(e.w, prompt)
+		line, err := e.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSuffix(line, "\n"), nil
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	e.buf, e.pos = nil, 0
+	// hist is the line of history being edited, len(e.history) for the
+	// new line, which is saved while moving through the history
+	hist, saved := len(e.history), ""
+	e.draw(prompt, true)
+	for {
+		k, err := e.readKey()
+		if err == nil && k == keyCtrlR {
+			k, err = e.search()
+		}
+		if err != nil {
+			return "", err
+		}
+		switch k {
+		case keyEnter, '\n':
+			e.draw(prompt, false)
+			io.WriteString(e.w, "\r\n")
+			line := string(e.buf)
+			e.addHistory(line)
+			return line, nil
+		case keyCtrlC:
+			io.WriteString(e.w, "^C\r\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				return "", io.EOF
+			}
+			e.delete(e.pos, e.pos+1)
+		case keyCtrlA, keyHome:
+			e.pos = 0
+		case keyCtrlE, keyEnd:
+			e.pos = len(e.buf)
+		case keyCtrlB, keyLeft:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case keyCtrlF, keyRight:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyBackspace, keyCtrlH:
+			e.delete(e.pos-1, e.pos)
+		case keyDelete:
+			e.delete(e.pos, e.pos+1)
+		case keyCtrlK:
+			e.delete(e.pos, len(e.buf))
+		case keyCtrlU:
+			e.delete(0, e.pos)
+		case keyCtrlW:
+			i := e.pos
+			for i > 0 && unicode.IsSpace(e.buf[i-1]) {
+				i--
+			}
+			for i > 0 && !unicode.IsSpace(e.buf[i-1]) {
+				i--
+			}
+			e.delete(i, e.pos)
+		case keyCtrlL:
+			io.WriteString(e.w, "\x1b[H\x1b[2J")
+		case keyCtrlP, keyUp:
+			if hist > 0 {
+				if hist == len(e.history) {
+					saved = string(e.buf)
+				}
+				hist--
+				e.set(e.history[hist])
+			}
+		case keyCtrlN, keyDown:
+			if hist < len(e.history) {
+				hist++
+				if hist == len(e.history) {
+					e.set(saved)
+				} else {
+					e.set(e.history[hist])
+				}
+			}
+		case keyTab:
+			e.completeWord(prompt)
+		default:
+			if k >= ' ' && k <= unicode.MaxRune {
+				e.insert(string(k))
+			}
+		}
+		e.draw(prompt, true)
+	}
+}
+
+// readKey reads a key, decoding the escape sequences of the cursor keys.
+func (e *lineEditor) readKey() (rune, error) {
+	r, _, err := e.r.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+	// ESC [ params final, or ESC O final
+	if r, _, err = e.r.ReadRune(); err != nil || r != '[' && r != 'O' {
+		return keyUnknown, err
+	}
+	var params []rune
+	for {
+		if r, _, err = e.r.ReadRune(); err != nil {
+			return 0, err
+		}
+		if r != ';' && (r < '0' || r > '9') {
+			break
+		}
+		params = append(params, r)
+	}
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch string(params) {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyDelete, nil
+		}
+	}
+	return keyUnknown, nil
+}
+
+// search reads a reverse incremental search of the history, leaving the
+// match in the line. It returns the key that ended the search, or 0 if it
+// was cancelled.
+func (e *lineEditor) search() (rune, error) {
+	orig, origPos := e.buf, e.pos
+	var query []rune
+	// i is the line of history matched
+	i, failed := len(e.history), false
+	for {
+		label := "reverse-i-search"
+		if failed {
+			label = "failing " + label
+		}
+		e.draw(fmt.Sprintf("(%s)`%s': ", label, string(query)), false)
+		k, err := e.readKey()
+		if err != nil {
+			return 0, err
+		}
+		from := i
+		switch {
+		case k == keyCtrlR:
+			from = i - 1
+		case k == keyBackspace || k == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			from = len(e.history) - 1
+		case k == keyCtrlG || k == keyCtrlC:
+			e.buf, e.pos = orig, origPos
+			return 0, nil
+		case k >= ' ' && k <= unicode.MaxRune:
+			query = append(query, k)
+		default:
+			return k, nil
+		}
+		if len(query) == 0 {
+			i, failed = len(e.history), false
+			e.buf, e.pos = orig, origPos
+			continue
+		}
+		if from >= len(e.history) {
+			from = len(e.history) - 1
+		}
+		failed = true
+		for j := from; j >= 0; j-- {
+			if n := strings.Index(e.history[j], string(query)); n >= 0 {
+				i, failed = j, false
+				e.set(e.history[j])
+				e.pos = utf8.RuneCountInString(e.history[j][:n])
+				break
+			}
+		}
+	}
+}
+
+// completeWord completes the symbol before the cursor as far as all its
+// completions agree, listing them if that adds nothing.
+func (e *lineEditor) completeWord(prompt string) {
+	start := e.pos
+	for start > 0 && !unicode.IsSpace(e.buf[start-1]) && !strings.ContainsRune("()'\";", e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	cs := e.complete(prefix)
+	if len(cs) == 0 {
+		return
+	}
+	// The prefix all the completions share, in runes so that it does not
+	// end within one
+	common := []rune(cs[0])
+	for _, c := range cs[1:] {
+		n := 0
+		for _, r := range c {
+			if n == len(common) || common[n] != r {
+				break
+			}
+			n++
+		}
+		common = common[:n]
+	}
+	if n := e.pos - start; len(common) > n {
+		e.insert(string(common[n:]))
+		return
+	}
+	if len(cs) == 1 {
+		return
+	}
+	width := 0
+	for _, c := range cs {
+		if n := utf8.RuneCountInString(c) + 2; n > width {
+			width = n
+		}
+	}
+	cols := termWidth(e.fd) / width
+	if cols < 1 {
+		cols = 1
+	}
+	var b strings.Builder
+	e.draw(prompt, false)
+	for i, c := range cs {
+		if i%cols == 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "%-*s", width, c)
+	}
+	b.WriteString("\r\n")
+	io.WriteString(e.w, b.String())
+}
+
+func (e *lineEditor) set(line string) {
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+}
+
+func (e *lineEditor) insert(s string) {
+	rs := []rune(s)
+	e.buf = append(e.buf[:e.pos], append(rs, e.buf[e.pos:]...)...)
+	e.pos += len(rs)
+}
+
+// delete removes the runes from i up to j, as far as there are any.
+func (e *lineEditor) delete(i, j int) {
+	if i < 0 {
+		i = 0
+	}
+	if j > len(e.buf) {
+		j = len(e.buf)
+	}
+	if i >= j {
+		return
+	}
+	e.buf = append(e.buf[:i:i], e.buf[j:]...)
+	if e.pos > j {
+		e.pos -= j - i
+	} else if e.pos > i {
+		e.pos = i
+	}
+}
+
+// draw redraws the line after prompt, with the parenthesis matching the
+// one before the cursor in reverse video if match is true.
+func (e *lineEditor) draw(prompt string, match bool) {
+	start, n := -1, 0
+	if match {
+		start, n = matchParen(e.buf, e.pos)
+	}
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	for i, r := range e.buf {
+		if i == start {
+			b.WriteString("\x1b[7m")
+		}
+		b.WriteRune(r)
+		if i == start+n-1 {
+			b.WriteString("\x1b[0m")
+		}
+	}
+	b.WriteString("\x1b[K\r")
+	if col := utf8.RuneCountInString(prompt) + e.pos; col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	io.WriteString(e.w, b.String())
+}
+
+// matchParen returns the index and length in runes of the parenthesis
+// matching the one that ends just before pos in line, or -1 if there is
+// none.
+func matchParen(line []rune, pos int) (start, n int) {
+	src := []byte(string(line))
+	end := len(string(line[:pos]))
+	toks := lisp.Tokenize(src)
+	for i, t := range toks {
+		if t.Kind != lisp.ParenToken || t.Offset+t.Len != end {
+			continue
+		}
+		var m *lisp.Token
+		if src[t.Offset] == ')' {
+			for j := i - 1; j >= 0 && m == nil; j-- {
+				if toks[j].Kind == lisp.ParenToken && toks[j].Depth == t.Depth {
+					m = &toks[j]
+				}
+			}
+		} else {
+			for j := i + 1; j < len(toks) && m == nil; j++ {
+				if toks[j].Kind == lisp.ParenToken && toks[j].Depth == t.Depth {
+					m = &toks[j]
+				}
+			}
+		}
+		if m == nil {
+			break
+		}
+		return utf8.RuneCount(src[:m.Offset]), utf8.RuneCount(src[m.Offset : m.Offset+m.Len])
+	}
+	return -1, 0
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+// newTestEditor returns a line editor reading the keys in input, with
+// history, that is not attached to a terminal.
+func newTestEditor(input string, history ...string) *lineEditor {
+	return &lineEditor{
+		r:       bufio.NewReader(strings.NewReader(input)),
+		w:       io.Discard,
+		history: history,
+	}
+}
+
+func TestMatchParen(t *testing.T) {
+	tests := []struct {
+		line     string
+		pos      int
+		start, n int
+	}{
+		{"(a b)", 5, 0, 1},
+		{"(a b)", 1, 4, 1},
+		{"(a b)", 3, -1, 0}, // Not after a parenthesis
+		{"(a (b) c)", 6, 3, 1},
+		{"(a (b) c)", 4, 5, 1},
+		{"(a (b) c)", 9, 0, 1},
+		{"(a (b", 4, -1, 0}, // Unbalanced
+		{"(a (b)", 6, 3, 1},
+		{"(a (b)", 1, -1, 0},
+		{"a)", 2, -1, 0},
+		{"))", 2, -1, 0},
+		{`("(" x)`, 7, 0, 1}, // Parentheses in strings do not count
+		{`("(" x)`, 3, -1, 0},
+		{`(a ")")`, 7, 0, 1},
+		{"#(1 2)", 6, 0, 2}, // A vector opens with #(
+		{"#(1 2)", 2, 5, 1},
+		{"(λ (μ))", 7, 0, 1}, // Positions are in runes
+		{"(λ (μ))", 6, 3, 1},
+		{"", 0, -1, 0},
+	}
+	for _, tst := range tests {
+		start, n := matchParen([]rune(tst.line), tst.pos)
+		if start != tst.start || n != tst.n {
+			t.Errorf("For %q at %d expected %d %d, got %d %d", tst.line, tst.pos, tst.start, tst.n, start, n)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		pos      int
+		i, j     int
+		expected string
+		newPos   int
+	}{
+		{5, 4, 5, "hell", 4},
+		{5, 0, 5, "", 0},
+		{2, 2, 3, "helo", 2},
+		{2, 0, 2, "llo", 0},
+		{3, 1, 2, "hllo", 2},   // Before the cursor
+		{1, 3, 4, "helo", 1},   // After the cursor
+		{0, -1, 0, "hello", 0}, // Backspace at the start
+		{5, 5, 6, "hello", 5},  // Delete at the end
+		{2, -3, 1, "ello", 1},
+		{2, 3, 10, "hel", 2},
+		{2, 4, 2, "hello", 2},
+		{4, -5, 10, "", 0},
+	}
+	for _, tst := range tests {
+		e := newTestEditor("")
+		e.set("hello")
+		e.pos = tst.pos
+		e.delete(tst.i, tst.j)
+		if string(e.buf) != tst.expected || e.pos != tst.newPos {
+			t.Errorf("Deleting %d to %d at %d: expected %q at %d, got %q at %d", tst.i, tst.j, tst.pos, tst.expected, tst.newPos, string(e.buf), e.pos)
+		}
+	}
+}
+
+func TestInsert(t *testing.T) {
+	e := newTestEditor("")
+	e.insert("(car)")
+	e.pos = 4
+	e.insert(" λs")
+	if string(e.buf) != "(car λs)" || e.pos != 7 {
+		t.Errorf("Expected %q at 7, got %q at %d", "(car λs)", string(e.buf), e.pos)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	e := newTestEditor("aλ\x01\r\x1b[A\x1b[B\x1b[C\x1b[D\x1bOH\x1bOF\x1b[1~\x1b[4~\x1b[3~\x1b[1;5C\x1b[9~\x1bx\x7f")
+	expected := []rune{'a', 'λ', keyCtrlA, keyEnter, keyUp, keyDown, keyRight, keyLeft, keyHome, keyEnd,
+		keyHome, keyEnd, keyDelete, keyRight, keyUnknown, keyUnknown, keyBackspace}
+	for i, want := range expected {
+		k, err := e.readKey()
+		if err != nil || k != want {
+			t.Fatalf("Key %d: expected %d, got %d %v", i, want, k, err)
+		}
+	}
+	if k, err := e.readKey(); err != io.EOF {
+		t.Errorf("Expected EOF, got %d %v", k, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	history := []string{"(define a 1)", "(car x)", "(define b 2)", "(cdr x)"}
+	tests := []struct {
+		keys     string
+		key      rune // The key that ended the search
+		expected string
+		pos      int
+	}{
+		{"def\r", keyEnter, "(define b 2)", 1},
+		{"def\x12\r", keyEnter, "(define a 1)", 1},
+		{"def\x12\x12\r", keyEnter, "(define a 1)", 1}, // No earlier match
+		{"x)\x1b[D", keyLeft, "(cdr x)", 5},
+		{"car\x7f\x7fd\r", keyEnter, "(cdr x)", 1},
+		{"zz\r", keyEnter, "typed", 5}, // No match leaves the line
+		{"def\x07", 0, "typed", 5},     // Ctrl-G cancels
+	}
+	for _, tst := range tests {
+		e := newTestEditor(tst.keys, history...)
+		e.set("typed")
+		k, err := e.search()
+		if err != nil || k != tst.key || string(e.buf) != tst.expected || e.pos != tst.pos {
+			t.Errorf("For %q expected %d %q at %d, got %d %q at %d %v", tst.keys, tst.key, tst.expected, tst.pos, k, string(e.buf), e.pos, err)
+		}
+	}
+}
+
+func TestCompleteWord(t *testing.T) {
+	names := []string{"string-append", "string-length", "string?", "substring", "car", "λab", "λac", "αλ", "αμ"}
+	complete := func(prefix string) []string {
+		var cs []string
+		for _, n := range names {
+			if strings.HasPrefix(n, prefix) {
+				cs = append(cs, n)
+			}
+		}
+		return cs
+	}
+	tests := []struct {
+		line     string
+		pos      int
+		expected string
+	}{
+		{"(str", 4, "(string"},
+		{"(string-a", 9, "(string-append"},
+		{"(ca x)", 3, "(car x)"},
+		{"'(su", 4, "'(substring"},
+		{"(foo", 4, "(foo"},
+		{"(car (s", 7, "(car (s"}, // Only the common prefix, which adds nothing
+		{"(λ", 2, "(λa"},
+		{"(α", 2, "(α"}, // λ and μ share their first byte but not their first rune
+	}
+	for _, tst := range tests {
+		e := newTestEditor("")
+		e.complete = complete
+		e.set(tst.line)
+		e.pos = tst.pos
+		e.completeWord("> ")
+		if string(e.buf) != tst.expected {
+			t.Errorf("Completing %q at %d: expected %q, got %q", tst.line, tst.pos, tst.expected, string(e.buf))
+		}
+	}
+}
+
+func TestReadLinePlain(t *testing.T) {
+	e := newTestEditor("(car '(1))\n(cdr\n")
+	var out strings.Builder
+	e.w = &out
+	for _, want := range []string{"(car '(1))", "(cdr"} {
+		if line, err := e.readLine("> "); line != want || err != nil {
+			t.Errorf("Expected %q, got %q %v", want, line, err)
+		}
+	}
+	if _, err := e.readLine("> "); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+	if out.String() != "> > > " {
+		t.Errorf("Unexpected prompts %q", out.String())
+	}
+}
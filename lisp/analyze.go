@@ -0,0 +1,589 @@
+package lisp
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// A node is an expression that has been checked and had its variables
+// resolved, ready to be evaluated by the tree walker or compiled for the VM.
+type node interface {
+	pos() (int, int)
+}
+
+// at is the source position of a node.
+type at struct {
+	row, col int
+}
+
+func (a at) pos() (int, int) {
+	return a.row, a.col
+}
+
+func atExpr(e *expr) at {
+	row, col := e.pos()
+	return at{row, col}
+}
+
+type constNode struct {
+	at
+	v Value
+}
+
+// localNode refers to the index'th variable of the frame depth frames up.
+type localNode struct {
+	at
+	depth, index int
+	sym          *Symbol
+}
+
+// globalNode refers to a variable of env, the global environment of the
+// program or module it appears in.
+type globalNode struct {
+	at
+	sym *Symbol
+	env map[*Symbol]Value
+}
+
+// setNode assigns to target, a *localNode or *globalNode.
+type setNode struct {
+	at
+	target node
+	value  node
+}
+
+// defineNode defines target, a *localNode in the current frame or a
+// *globalNode.
+type defineNode struct {
+	at
+	target node
+	value  node
+}
+
+type ifNode struct {
+	at
+	test, then, els node
+}
+
+// switchNode compares key, if any, against each test in turn.
+type switchNode struct {
+	at
+	key          node
+	tests, thens []node
+	def          node
+}
+
+// andNode and orNode evaluate exprs in turn until one is false or true
+// respectively, returning the value of the last evaluated.
+type andNode struct {
+	at
+	exprs []node
+}
+
+type orNode struct {
+	at
+	exprs []node
+}
+
+type seqNode struct {
+	at
+	body []node
+}
+
+type lambdaNode struct {
+	at
+	name    string
+	nparams int
+	rest    bool      // The last parameter collects any remaining arguments
+	names   []*Symbol // Parameters followed by local definitions
+	body    node
+	doc     string // The docstring, or ""
+	file    string // The file it is defined in, or ""
+}
+
+// signature shows how the procedure is called by name, as in (f x . rest).
+func (n *lambdaNode) signature(name string) string {
+	var b strings.Builder
+	b.WriteString("(" + name)
+	for i, p := range n.names[:n.nparams] {
+		if n.rest && i == n.nparams-1 {
+			b.WriteString(" .")
+		}
+		b.WriteString(" " + p.Name)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// letNode evaluates values in the current frame and body in a new frame
+// holding names.
+type letNode struct {
+	at
+	values []node
+	names  []*Symbol
+	body   node
+}
+
+type callNode struct {
+	at
+	f    node
+	args []node
+}
+
+// ErrorList is the list of errors found analyzing a program.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	var s []string
+	for _, e := range l {
+		s = append(s, e.Error())
+	}
+	return strings.Join(s, "\n")
+}
+
+// A scope holds the names of the variables in one frame. Variables are
+// addressed by how many frames up they are and their index in the frame.
+type scope struct {
+	names []*Symbol
+	up    *scope
+}
+
+func (s *scope) lookup(name *Symbol) (depth, index int, ok bool) {
+	for ; s != nil; s, depth = s.up, depth+1 {
+		for i := len(s.names) - 1; i >= 0; i-- {
+			if s.names[i] == name {
+				return depth, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// slot returns the index of name in s, adding it if necessary.
+func (s *scope) slot(name *Symbol) int {
+	for i, n := range s.names {
+		if n == name {
+			return i
+		}
+	}
+	s.names = append(s.names, name)
+	return len(s.names) - 1
+}
+
+type analyzer struct {
+	in    *Interpreter
+	scope *scope // nil at top level, where definitions are global
+	// bound reports whether a global variable is defined, or will be by
+	// the program being analyzed
+	bound func(name *Symbol) bool
+	errs  ErrorList
+	file  string // The file being analyzed, or ""
+	// lambdas counts the lambdas being analyzed, whose bodies may refer to
+	// globals that are not yet bound, which are listed in unbound
+	lambdas int
+	unbound ErrorList
+}
+
+// analyze checks the program es and resolves its variables. Globals
+// evaluated at the top level must either be defined in the interpreter
+// already or be defined at the top level of es, or of the files it loads,
+// or be imported by it.
+func (in *Interpreter) analyze(es []*expr) ([]node, error) {
+	ns, errs, _ := in.analyzeProgram(es)
+	if errs != nil {
+		return nil, errs
+	}
+	return ns, nil
+}
+
+// analyzeProgram is analyze, also returning the globals referred to by
+// procedures that are not bound, which fail only if called before they
+// are defined.
+func (in *Interpreter) analyzeProgram(es []*expr) ([]node, ErrorList, ErrorList) {
+	defined := map[*Symbol]bool{}
+	a := &analyzer{in: in, bound: func(name *Symbol) bool {
+		_, ok := in.global[name]
+		return ok || defined[name]
+	}}
+	if len(in.loading) > 0 {
+		a.file = displayPath(in.loading[len(in.loading)-1])
+	}
+	for _, e := range es {
+		if err := in.globalDefines(e, in.dir, defined, in.loading); err != nil {
+			a.errs = append(a.errs, err)
+		}
+	}
+	var ns []node
+	for _, e := range es {
+		ns = append(ns, a.analyze(e))
+	}
+	return ns, a.errs, a.unbound
+}
+
+// globalDefines adds the names defined at the top level of e, which is in
+// a file in dir, to names: those it defines, those defined by the files it
+// loads and those it imports. chain lists the files being loaded.
+func (in *Interpreter) globalDefines(e *expr, dir string, names map[*Symbol]bool, chain []string) *Error {
+	if !e.isList() || e.first == nil || e.lit != 0 {
+		return nil
+	}
+	args := e.items()[1:]
+	switch e.first.symbol() {
+	case "quote", "lambda", "let":
+		return nil
+	case "define":
+		if len(args) > 0 && args[0].isList() && args[0].first != nil {
+			if sym := args[0].first.sym(); sym != nil {
+				names[sym] = true
+			}
+			return nil
+		}
+		if len(args) > 0 && args[0].sym() != nil {
+			names[args[0].sym()] = true
+		}
+	case "load":
+		if len(args) != 1 || args[0].isList() || args[0].atom.typ != tokenString {
+			break
+		}
+		path, err := in.findFile(args[0].atom.val.(string), dir)
+		if err != nil {
+			return errorf(e, "%v", err)
+		}
+		if err := cycle(chain, path); err != nil {
+			return errorf(e, "%v", err)
+		}
+		es, err := readFile(path)
+		if le, ok := err.(*Error); ok {
+			return le
+		} else if err != nil {
+			return errorf(e, "%v", err)
+		}
+		chain = append(chain[:len(chain):len(chain)], path)
+		for _, x := range es {
+			if err := in.globalDefines(x, filepath.Dir(path), names, chain); err != nil {
+				setFile(err, displayPath(path))
+				return err
+			}
+		}
+		return nil
+	case "import":
+		file, alias, msg := importArgs(args)
+		if msg != "" {
+			return nil
+		}
+		path, err := in.findFile(file, dir)
+		if err != nil {
+			return errorf(e, "%v", err)
+		}
+		name, exports, err := readModule(path)
+		if le, ok := err.(*Error); ok {
+			return le
+		} else if err != nil {
+			return errorf(e, "%v", err)
+		}
+		if alias == nil {
+			alias = name
+		}
+		for _, x := range exports {
+			names[qualify(alias, x)] = true
+		}
+		return nil
+	}
+	for _, x := range e.items() {
+		if err := in.globalDefines(x, dir, names, chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *analyzer) errorf(e *expr, format string, args ...interface{}) node {
+	a.errs = append(a.errs, errorf(e, format, args...))
+	return &constNode{at: atExpr(e)}
+}
+
+func (a *analyzer) analyze(e *expr) node {
+	if e.labelled && e.lit == 0 || !e.isList() && e.atom.typ == tokenLabelRef {
+		return a.errorf(e, "Datum labels may only be used in quoted data")
+	}
+	if !e.isList() {
+		if e.atom.typ != tokenAtom {
+			return &constNode{atExpr(e), e.atom.val}
+		}
+		return a.variable(e)
+	}
+	if e.lit != 0 {
+		// Vector and hash table literals evaluate to themselves
+		return &constNode{atExpr(e), quote(e)}
+	}
+	if e.first == nil {
+		return &constNode{at: atExpr(e)}
+	}
+	if e.dotted() != nil {
+		return a.errorf(e, "Cannot evaluate an improper list")
+	}
+	args := e.items()[1:]
+	switch e.first.symbol() {
+	case "quote":
+		if len(args) != 1 {
+			return a.errorf(e, "quote expects 1 argument, got %d", len(args))
+		}
+		return &constNode{atExpr(e), quote(args[0])}
+	case "if":
+		return a.analyzeIf(e, args)
+	case "switch":
+		return a.analyzeSwitch(e, args)
+	case "do":
+		return a.analyzeBody(e, args)
+	case "and":
+		if len(args) == 0 {
+			return &constNode{atExpr(e), true}
+		}
+		return &andNode{atExpr(e), a.analyzeAll(args)}
+	case "or":
+		if len(args) == 0 {
+			return &constNode{atExpr(e), false}
+		}
+		return &orNode{atExpr(e), a.analyzeAll(args)}
+	case "define":
+		return a.analyzeDefine(e, args)
+	case "set!":
+		return a.analyzeSet(e, args)
+	case "lambda":
+		if len(args) < 2 {
+			return a.errorf(e, "lambda expects parameters and a body")
+		}
+		return a.analyzeLambda(e, "", args[0], args[1:])
+	case "let":
+		return a.analyzeLet(e, args)
+	case "module":
+		return a.analyzeModule(e, args)
+	case "import":
+		return a.analyzeImport(e, args)
+	}
+	n := &callNode{at: atExpr(e), f: a.analyze(e.first)}
+	for _, arg := range args {
+		n.args = append(n.args, a.analyze(arg))
+	}
+	return n
+}
+
+// variable resolves the symbol e.
+func (a *analyzer) variable(e *expr) node {
+	name := e.sym()
+	if d, i, ok := a.scope.lookup(name); ok {
+		return &localNode{atExpr(e), d, i, name}
+	}
+	if !a.bound(name) {
+		if a.lambdas == 0 {
+			return a.errorf(e, "Unbound variable %s", name)
+		}
+		// The procedure may be called after name is defined, as by a later
+		// line of the REPL
+		a.unbound = append(a.unbound, errorf(e, "Unbound variable %s", name))
+	}
+	return &globalNode{atExpr(e), name, a.in.global}
+}
+
+func (a *analyzer) analyzeAll(es []*expr) []node {
+	ns := make([]node, len(es))
+	for i, e := range es {
+		ns[i] = a.analyze(e)
+	}
+	return ns
+}
+
+// analyzeBody analyzes a sequence whose value is that of its last
+// expression.
+func (a *analyzer) analyzeBody(e *expr, body []*expr) node {
+	if len(body) == 0 {
+		return &constNode{at: atExpr(e)}
+	}
+	if len(body) == 1 {
+		return a.analyze(body[0])
+	}
+	n := &seqNode{at: atExpr(e)}
+	for _, b := range body {
+		n.body = append(n.body, a.analyze(b))
+	}
+	return n
+}
+
+// declare adds the names defined by body to the current scope so that they
+// are local to it even where they are referred to before being defined.
+func (a *analyzer) declare(body []*expr) {
+	for _, e := range body {
+		if !e.isList() || e.first == nil || e.lit != 0 {
+			continue
+		}
+		args := e.items()[1:]
+		switch e.first.symbol() {
+		case "define":
+			if len(args) > 0 && args[0].isList() && args[0].first != nil {
+				args[0] = args[0].first
+			}
+			if len(args) > 0 && args[0].sym() != nil {
+				a.scope.slot(args[0].sym())
+			}
+		case "do":
+			a.declare(args)
+		}
+	}
+}
+
+// (if test then [else])
+func (a *analyzer) analyzeIf(e *expr, args []*expr) node {
+	if len(args) != 2 && len(args) != 3 {
+		return a.errorf(e, "if expects 2 or 3 arguments, got %d", len(args))
+	}
+	n := &ifNode{at: atExpr(e), test: a.analyze(args[0]), then: a.analyze(args[1])}
+	if len(args) == 3 {
+		n.els = a.analyze(args[2])
+	} else {
+		n.els = &constNode{at: atExpr(e)}
+	}
+	return n
+}
+
+// (switch () test value ... [default])
+// (switch (key) match value ... [default])
+//
+// With an empty key list the first value whose test is true is returned,
+// otherwise the first value whose match is equal to key. A trailing odd
+// expression is the default.
+func (a *analyzer) analyzeSwitch(e *expr, args []*expr) node {
+	if len(args) == 0 || !args[0].isList() || len(args[0].items()) > 1 {
+		return a.errorf(e, "switch expects a key list of at most one expression")
+	}
+	n := &switchNode{at: atExpr(e)}
+	if args[0].first != nil {
+		n.key = a.analyze(args[0].first)
+	}
+	clauses := args[1:]
+	for ; len(clauses) >= 2; clauses = clauses[2:] {
+		n.tests = append(n.tests, a.analyze(clauses[0]))
+		n.thens = append(n.thens, a.analyze(clauses[1]))
+	}
+	if len(clauses) == 1 {
+		n.def = a.analyze(clauses[0])
+	} else {
+		n.def = &constNode{at: atExpr(e)}
+	}
+	return n
+}
+
+// (define name value)
+// (define (name params ...) body ...)
+func (a *analyzer) analyzeDefine(e *expr, args []*expr) node {
+	if len(args) < 2 {
+		return a.errorf(e, "define expects at least 2 arguments, got %d", len(args))
+	}
+	n := &defineNode{at: atExpr(e)}
+	var name *expr
+	if args[0].isList() {
+		sig := args[0].items()
+		if len(sig) == 0 || sig[0].symbol() == "" {
+			return a.errorf(e, "define expects a procedure name")
+		}
+		name = sig[0]
+		params := args[0].rest
+		if params == nil {
+			params = args[0].tail
+		}
+		n.value = a.analyzeLambda(e, name.symbol(), params, args[1:])
+	} else {
+		if name = args[0]; name.symbol() == "" || len(args) != 2 {
+			return a.errorf(e, "define expects a name and a value")
+		}
+		n.value = a.analyze(args[1])
+	}
+	if a.scope == nil {
+		n.target = &globalNode{atExpr(name), name.sym(), a.in.global}
+	} else {
+		n.target = &localNode{atExpr(name), 0, a.scope.slot(name.sym()), name.sym()}
+	}
+	return n
+}
+
+// (set! name value)
+func (a *analyzer) analyzeSet(e *expr, args []*expr) node {
+	if len(args) != 2 || args[0].symbol() == "" {
+		return a.errorf(e, "set! expects a name and a value")
+	}
+	return &setNode{atExpr(e), a.variable(args[0]), a.analyze(args[1])}
+}
+
+// (lambda (params ...) [docstring] body ...)
+// (lambda (params ... . rest) [docstring] body ...)
+// (lambda rest [docstring] body ...)
+//
+// A string before the rest of the body documents the procedure.
+func (a *analyzer) analyzeLambda(e *expr, name string, params *expr, body []*expr) node {
+	s := &scope{up: a.scope}
+	n := &lambdaNode{at: atExpr(e), name: name, file: a.file}
+	if params != nil && !params.isList() {
+		if params.symbol() == "" {
+			return a.errorf(params, "Invalid parameter %s", params.atom.raw)
+		}
+		s.names = []*Symbol{params.sym()}
+		n.rest = true
+	} else if params != nil {
+		for _, p := range params.items() {
+			if p.symbol() == "" {
+				return a.errorf(p, "Invalid parameter")
+			}
+			s.names = append(s.names, p.sym())
+		}
+		if r := params.dotted(); r != nil {
+			if r.sym() == nil {
+				return a.errorf(r, "Invalid parameter")
+			}
+			s.names = append(s.names, r.sym())
+			n.rest = true
+		}
+	}
+	n.nparams = len(s.names)
+	if n.doc = docstring(body); n.doc != "" {
+		body = body[1:]
+	}
+	a.scope = s
+	a.lambdas++
+	a.declare(body)
+	n.body = a.analyzeBody(e, body)
+	a.lambdas--
+	a.scope = s.up
+	n.names = s.names
+	return n
+}
+
+// docstring returns the docstring that starts the procedure body, if it
+// has one.
+func docstring(body []*expr) string {
+	if len(body) > 1 && !body[0].isList() && body[0].atom.typ == tokenString {
+		return body[0].atom.val.(string)
+	}
+	return ""
+}
+
+// (let ((name value) ...) body ...)
+func (a *analyzer) analyzeLet(e *expr, args []*expr) node {
+	if len(args) < 2 || !args[0].isList() {
+		return a.errorf(e, "let expects bindings and a body")
+	}
+	n := &letNode{at: atExpr(e)}
+	s := &scope{up: a.scope}
+	for _, b := range args[0].items() {
+		kv := b.items()
+		if !b.isList() || len(kv) != 2 || kv[0].symbol() == "" {
+			return a.errorf(b, "let expects bindings of the form (name value)")
+		}
+		n.values = append(n.values, a.analyze(kv[1]))
+		s.names = append(s.names, kv[0].sym())
+	}
+	a.scope = s
+	a.declare(args[1:])
+	n.body = a.analyzeBody(e, args[1:])
+	a.scope = s.up
+	n.names = s.names
+	return n
+}
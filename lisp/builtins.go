@@ -0,0 +1,395 @@
+package lisp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+var builtins = []*Builtin{
+	{"+", builtinAdd, 0, -1, "(+ x ...)\nReturns the sum of the numbers, or 0."},
+	{"-", builtinSub, 1, -1, "(- x y ...)\nSubtracts the rest from x, or negates x if it is alone."},
+	{"*", builtinMul, 0, -1, "(* x ...)\nReturns the product of the numbers, or 1."},
+	{"/", builtinDiv, 1, -1, "(/ x y ...)\nDivides x by the rest, or inverts x if it is alone. Integer division that is not exact gives a float."},
+	{"mod", builtinMod, 2, 2, "(mod x y)\nReturns the remainder of dividing x by y."},
+	{"=", comparison("=", func(c int) bool { return c == 0 }), 1, -1, "(= x y ...)\nReports whether the numbers are equal."},
+	{"<", comparison("<", func(c int) bool { return c < 0 }), 1, -1, "(< x y ...)\nReports whether the numbers are increasing."},
+	{">", comparison(">", func(c int) bool { return c > 0 }), 1, -1, "(> x y ...)\nReports whether the numbers are decreasing."},
+	{"<=", comparison("<=", func(c int) bool { return c <= 0 }), 1, -1, "(<= x y ...)\nReports whether the numbers are not decreasing."},
+	{">=", comparison(">=", func(c int) bool { return c >= 0 }), 1, -1, "(>= x y ...)\nReports whether the numbers are not increasing."},
+	{"null?", builtinIsNull, 1, 1, "(null? x)\nReports whether x is the empty list."},
+	{"boolean?", builtinIsBoolean, 1, 1, "(boolean? x)\nReports whether x is #t or #f."},
+	{"not", builtinNot, 1, 1, "(not x)\nReturns #t if x is false, which only #f and () are, otherwise #f."},
+	{"eq?", builtinEq, 2, 2, "(eq? x y)\nReports whether x and y are the same value."},
+	{"equal?", builtinEqual, 2, 2, "(equal? x y)\nReports whether x and y have the same structure and contents."},
+	{"symbol?", builtinIsSymbol, 1, 1, "(symbol? x)\nReports whether x is a symbol."},
+	{"symbol->string", builtinSymbolToString, 1, 1, "(symbol->string sym)\nReturns the name of sym."},
+	{"string->symbol", builtinStringToSymbol("string->symbol"), 1, 1, "(string->symbol s)\nReturns the symbol named s."},
+	{"intern", builtinStringToSymbol("intern"), 1, 1, "(intern s)\nReturns the symbol named s."},
+	{"display", builtinDisplay, 0, -1, "(display x ...)\nWrites each x to standard output, with strings and characters bare."},
+	{"write", builtinWrite, 0, -1, "(write x ...)\nWrites each x to standard output as it would be read."},
+	{"pp", builtinPP, 1, 2, "(pp x [width])\nWrites x to standard output across lines so that it fits within width columns, 80 by default."},
+	{"newline", builtinNewline, 0, 0, "(newline)\nWrites a newline to standard output."},
+	{"warn", builtinWarn, 0, -1, "(warn x ...)\nWrites each x to standard error as display does, followed by a newline."},
+	{"read-line", builtinReadLine, 0, 0, "(read-line)\nReads a line from standard input, returning it without its line ending, or the eof object at the end of input."},
+	{"read", builtinRead, 0, 0, "(read)\nReads an expression from standard input, returning it unevaluated, or the eof object at the end of input."},
+	{"eof-object?", builtinIsEOF, 1, 1, "(eof-object? x)\nReports whether x is the eof object, which read and read-line return at the end of input."},
+	{"error", builtinError, 1, -1, "(error message irritant ...)\nRaises an error with message displayed and the irritants written after it."},
+	{"load", builtinLoad, 1, 1, "(load file)\nEvaluates the expressions of file in the current global environment, returning the value of the last. A relative file is looked for in the directory of the file loading it and then in $LISPPATH, with or without the extension .lisp."},
+	{"doc", builtinDoc, 1, 1, "(doc f)\n(doc 'name)\nReturns the documentation of the procedure f, or of what name is bound to, or #f if it has none. The documentation of a lambda is how it is called followed by its docstring."},
+	{"apropos", builtinApropos, 1, 1, "(apropos s)\nReturns the sorted names of the special forms and variables whose name or documentation contains s, ignoring case."},
+}
+
+// constants are the variables every interpreter starts with besides the
+// builtins.
+var constants = map[string]Value{
+	"true":  true,
+	"false": false,
+	"nil":   nil,
+}
+
+// checkArgs returns an error unless name was given between min and max
+// arguments. A negative max means no upper bound.
+func checkArgs(name string, args []Value, min, max int) error {
+	return checkArity(name, len(args), min, max)
+}
+
+// checkArity returns an error unless n is between min and max, as
+// checkArgs does.
+func checkArity(name string, n, min, max int) error {
+	switch {
+	case min == max && n != min:
+		return fmt.Errorf("%s expects %d arguments, got %d", name, min, n)
+	case n < min:
+		return fmt.Errorf("%s expects at least %d arguments, got %d", name, min, n)
+	case max >= 0 && n > max:
+		return fmt.Errorf("%s expects at most %d arguments, got %d", name, max, n)
+	}
+	return nil
+}
+
+func checkNumbers(name string, args []Value) error {
+	for _, a := range args {
+		if !isNumber(a) {
+			return fmt.Errorf("%s expects numbers, got %s", name, String(a))
+		}
+	}
+	return nil
+}
+
+func toFloat(v Value) float64 {
+	if i, ok := v.(int); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+// arith applies the int or float64 operation to a and b, using floats if
+// either is a float.
+func arith(a, b Value, i func(x, y int) int, f func(x, y float64) float64) Value {
+	x, xok := a.(int)
+	y, yok := b.(int)
+	if xok && yok {
+		return i(x, y)
+	}
+	return f(toFloat(a), toFloat(b))
+}
+
+// compare returns -1, 0 or 1 as number a is less than, equal to or greater
+// than number b.
+func compare(a, b Value) (int, error) {
+	if !isNumber(a) || !isNumber(b) {
+		return 0, fmt.Errorf("cannot compare %s and %s", String(a), String(b))
+	}
+	x, y := toFloat(a), toFloat(b)
+	switch {
+	case x < y:
+		return -1, nil
+	case x > y:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func fold(name string, args []Value, init Value, i func(x, y int) int, f func(x, y float64) float64) (Value, error) {
+	if err := checkNumbers(name, args); err != nil {
+		return nil, err
+	}
+	acc := init
+	for _, a := range args {
+		acc = arith(acc, a, i, f)
+	}
+	return acc, nil
+}
+
+func builtinAdd(in *Interpreter, args []Value) (Value, error) {
+	return fold("+", args, 0, func(x, y int) int { return x + y }, func(x, y float64) float64 { return x + y })
+}
+
+func builtinMul(in *Interpreter, args []Value) (Value, error) {
+	return fold("*", args, 1, func(x, y int) int { return x * y }, func(x, y float64) float64 { return x * y })
+}
+
+// (- x) negates x, (- x y ...) subtracts the rest from x.
+func builtinSub(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("-", args, 1, -1); err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		args = []Value{0, args[0]}
+	}
+	if err := checkNumbers("-", args[:1]); err != nil {
+		return nil, err
+	}
+	return fold("-", args[1:], args[0], func(x, y int) int { return x - y }, func(x, y float64) float64 { return x - y })
+}
+
+// (/ x) inverts x, (/ x y ...) divides x by the rest. Integer division
+// that is not exact produces a float.
+func builtinDiv(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("/", args, 1, -1); err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		args = []Value{1, args[0]}
+	}
+	if err := checkNumbers("/", args); err != nil {
+		return nil, err
+	}
+	acc := args[0]
+	for _, a := range args[1:] {
+		x, xok := acc.(int)
+		y, yok := a.(int)
+		switch {
+		case xok && yok && y == 0:
+			return nil, fmt.Errorf("/: division by zero")
+		case xok && yok && x%y == 0:
+			acc = x / y
+		default:
+			acc = toFloat(acc) / toFloat(a)
+		}
+	}
+	return acc, nil
+}
+
+func builtinMod(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("mod", args, 2, 2); err != nil {
+		return nil, err
+	}
+	if err := checkNumbers("mod", args); err != nil {
+		return nil, err
+	}
+	if y, ok := args[1].(int); ok && y == 0 {
+		return nil, fmt.Errorf("mod: division by zero")
+	}
+	return arith(args[0], args[1], func(x, y int) int { return x % y }, math.Mod), nil
+}
+
+// comparison returns a builtin that reports whether every adjacent pair of
+// its arguments satisfies ok.
+func comparison(name string, ok func(c int) bool) func(in *Interpreter, args []Value) (Value, error) {
+	return func(in *Interpreter, args []Value) (Value, error) {
+		if err := checkArgs(name, args, 1, -1); err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(args); i++ {
+			c, err := compare(args[i-1], args[i])
+			if err != nil {
+				return nil, err
+			}
+			if !ok(c) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
+
+func builtinIsNull(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("null?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	return args[0] == nil, nil
+}
+
+func builtinIsBoolean(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("boolean?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(bool)
+	return ok, nil
+}
+
+func builtinNot(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("not", args, 1, 1); err != nil {
+		return nil, err
+	}
+	return !truthy(args[0]), nil
+}
+
+func builtinEq(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("eq?", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return args[0] == args[1], nil
+}
+
+func builtinEqual(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("equal?", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return equal(args[0], args[1]), nil
+}
+
+func builtinIsSymbol(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("symbol?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(*Symbol)
+	return ok, nil
+}
+
+func builtinSymbolToString(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("symbol->string", args, 1, 1); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("symbol->string expects a symbol, got %s", String(args[0]))
+	}
+	return s.Name, nil
+}
+
+// builtinStringToSymbol returns the symbol named by its string argument.
+// Any string may be interned, even one that would not read as a symbol.
+func builtinStringToSymbol(name string) func(*Interpreter, []Value) (Value, error) {
+	return func(in *Interpreter, args []Value) (Value, error) {
+		if err := checkArgs(name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s expects a string, got %s", name, String(args[0]))
+		}
+		return Intern(s), nil
+	}
+}
+
+// (display value ...) writes each value to standard output without quoting.
+func builtinDisplay(in *Interpreter, args []Value) (Value, error) {
+	for _, a := range args {
+		if err := Print(in.stdout, a); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// (write value ...) writes each value to standard output as it would be
+// read.
+func builtinWrite(in *Interpreter, args []Value) (Value, error) {
+	for _, a := range args {
+		if err := Write(in.stdout, a); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// (pp value [width]) pretty prints value on standard output, followed by a
+// newline.
+func builtinPP(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("pp", args, 1, 2); err != nil {
+		return nil, err
+	}
+	width := DefaultWidth
+	if len(args) == 2 {
+		w, ok := args[1].(int)
+		if !ok || w <= 0 {
+			return nil, fmt.Errorf("pp expects a positive width, got %s", String(args[1]))
+		}
+		width = w
+	}
+	if err := PrettyPrint(in.stdout, args[0], width); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(in.stdout)
+	return nil, nil
+}
+
+func builtinNewline(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("newline", args, 0, 0); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(in.stdout)
+	return nil, nil
+}
+
+// (warn value ...) displays each value on standard error, then a newline.
+func builtinWarn(in *Interpreter, args []Value) (Value, error) {
+	for _, a := range args {
+		if err := Print(in.stderr, a); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintln(in.stderr)
+	return nil, nil
+}
+
+func builtinReadLine(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("read-line", args, 0, 0); err != nil {
+		return nil, err
+	}
+	line, err := in.stdin.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return EOF, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// (read) parses the next expression on standard input.
+func builtinRead(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("read", args, 0, 0); err != nil {
+		return nil, err
+	}
+	l := newLexer(in.stdin, in.opts)
+	e, err := newParser(l).next()
+	if l.peeking && l.curr != EOFRUNE {
+		// Leave the rune the lexer looked ahead at for the next read
+		in.stdin.UnreadRune()
+	}
+	if err == io.EOF {
+		return EOF, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return quote(e), nil
+}
+
+func builtinIsEOF(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("eof-object?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	return args[0] == EOF, nil
+}
+
+// (error message irritant ...) raises an error whose message is message
+// displayed followed by the irritants written.
+func builtinError(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("error", args, 1, -1); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	Print(&b, args[0])
+	for _, a := range args[1:] {
+		b.WriteByte(' ')
+		Write(&b, a)
+	}
+	return nil, errors.New(b.String())
+}
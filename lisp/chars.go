@@ -0,0 +1,94 @@
+package lisp
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+var charBuiltins = []*Builtin{
+	{"char?", builtinIsChar, 1, 1, "(char? x)\nReports whether x is a character."},
+	{"char->integer", builtinCharToInteger, 1, 1, "(char->integer c)\nReturns the code point of c."},
+	{"integer->char", builtinIntegerToChar, 1, 1, "(integer->char n)\nReturns the character with code point n."},
+	{"char-alphabetic?", charPredicate("char-alphabetic?", unicode.IsLetter), 1, 1, "(char-alphabetic? c)\nReports whether c is a letter."},
+	{"char-numeric?", charPredicate("char-numeric?", unicode.IsDigit), 1, 1, "(char-numeric? c)\nReports whether c is a digit."},
+	{"char-whitespace?", charPredicate("char-whitespace?", unicode.IsSpace), 1, 1, "(char-whitespace? c)\nReports whether c is white space."},
+	{"char-upper-case?", charPredicate("char-upper-case?", unicode.IsUpper), 1, 1, "(char-upper-case? c)\nReports whether c is an upper case letter."},
+	{"char-lower-case?", charPredicate("char-lower-case?", unicode.IsLower), 1, 1, "(char-lower-case? c)\nReports whether c is a lower case letter."},
+	{"char-upcase", charMap("char-upcase", unicode.ToUpper), 1, 1, "(char-upcase c)\nReturns c in upper case."},
+	{"char-downcase", charMap("char-downcase", unicode.ToLower), 1, 1, "(char-downcase c)\nReturns c in lower case."},
+}
+
+func toChar(name string, v Value) (Char, error) {
+	c, ok := v.(Char)
+	if !ok {
+		return 0, fmt.Errorf("%s expects a character, got %s", name, String(v))
+	}
+	return c, nil
+}
+
+func builtinIsChar(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("char?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Char)
+	return ok, nil
+}
+
+func builtinCharToInteger(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("char->integer", args, 1, 1); err != nil {
+		return nil, err
+	}
+	c, err := toChar("char->integer", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return int(c), nil
+}
+
+func builtinIntegerToChar(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("integer->char", args, 1, 1); err != nil {
+		return nil, err
+	}
+	n, ok := args[0].(int)
+	if !ok || !isCodePoint(n) {
+		return nil, fmt.Errorf("integer->char expects a code point, got %s", String(args[0]))
+	}
+	return Char(n), nil
+}
+
+// isCodePoint reports whether n is a Unicode code point that is not a
+// surrogate. Its range is checked before it is converted to a rune, which
+// would wrap.
+func isCodePoint(n int) bool {
+	return n >= 0 && n <= utf8.MaxRune && utf8.ValidRune(rune(n))
+}
+
+// charPredicate returns a builtin reporting whether its character argument
+// satisfies f.
+func charPredicate(name string, f func(rune) bool) func(*Interpreter, []Value) (Value, error) {
+	return func(in *Interpreter, args []Value) (Value, error) {
+		if err := checkArgs(name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		c, err := toChar(name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(rune(c)), nil
+	}
+}
+
+// charMap returns a builtin applying f to its character argument.
+func charMap(name string, f func(rune) rune) func(*Interpreter, []Value) (Value, error) {
+	return func(in *Interpreter, args []Value) (Value, error) {
+		if err := checkArgs(name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		c, err := toChar(name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return Char(f(rune(c))), nil
+	}
+}
@@ -0,0 +1,299 @@
+package lisp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type opcode byte
+
+// Operands follow their opcode as big endian uint16s.
+const (
+	opConst       opcode = iota // const: push consts[const]
+	opLocal                     // depth index: push a local variable
+	opSetLocal                  // depth index: assign the top of stack to a local
+	opDefLocal                  // index: pop into a local of the current frame, push its symbol
+	opGlobal                    // global: push the global *globalNode consts[global]
+	opSetGlobal                 // global: assign the top of stack to a global
+	opDefGlobal                 // global: pop into a global, push its symbol
+	opPop                       // discard the top of stack
+	opDup                       // push the top of stack again
+	opEqual                     // pop two values, push whether they are equal
+	opJump                      // target: continue at target
+	opJumpIfFalse               // target: pop, continue at target if false
+	opClosure                   // const: push a closure of the proto consts[const]
+	opCall                      // argc: call the procedure below argc arguments
+	opTailCall                  // argc: call, replacing the current frame
+	opReturn                    // return the top of stack
+	opPushEnv                   // n names: pop n values into a new frame for consts[names]
+	opPopEnv                    // return to the enclosing frame
+	opFail                      // const: raise the *Error consts[const]
+)
+
+// A proto is a compiled lambda body, or a compiled top level expression.
+type proto struct {
+	lambda *lambdaNode // nil at top level
+	code   []byte
+	consts []Value
+	pos    []pcPos
+}
+
+// pcPos maps the instructions from pc onwards to a source position.
+type pcPos struct {
+	pc, row, col int
+}
+
+// position returns the source position of the instruction at pc.
+func (p *proto) position(pc int) (int, int) {
+	row, col := 0, 0
+	for _, pp := range p.pos {
+		if pp.pc > pc {
+			break
+		}
+		row, col = pp.row, pp.col
+	}
+	return row, col
+}
+
+type compiler struct {
+	p *proto
+	// overflow is set when an operand does not fit in 16 bits
+	overflow bool
+}
+
+// maxOperand is the largest operand an instruction holds.
+const maxOperand = 0xffff
+
+// compileTop compiles a top level expression.
+func compileTop(n node) (*proto, error) {
+	c := &compiler{p: &proto{}}
+	if err := c.compile(n, false); err != nil {
+		return nil, err
+	}
+	c.emit(opReturn)
+	return c.p, nil
+}
+
+func compileLambda(n *lambdaNode) (*proto, error) {
+	c := &compiler{p: &proto{lambda: n}}
+	if err := c.compile(n.body, true); err != nil {
+		return nil, err
+	}
+	c.emit(opReturn)
+	return c.p, nil
+}
+
+func (c *compiler) emit(op opcode, args ...int) int {
+	pc := len(c.p.code)
+	c.p.code = append(c.p.code, byte(op))
+	for _, a := range args {
+		if a > maxOperand {
+			c.overflow = true
+		}
+		c.p.code = binary.BigEndian.AppendUint16(c.p.code, uint16(a))
+	}
+	return pc
+}
+
+// patch sets the first operand of the instruction at pc to the current pc.
+func (c *compiler) patch(pc int) {
+	if len(c.p.code) > maxOperand {
+		c.overflow = true
+	}
+	binary.BigEndian.PutUint16(c.p.code[pc+1:], uint16(len(c.p.code)))
+}
+
+func (c *compiler) constant(v Value) int {
+	c.p.consts = append(c.p.consts, v)
+	return len(c.p.consts) - 1
+}
+
+// mark records that the following instructions come from n.
+func (c *compiler) mark(n node) {
+	row, col := n.pos()
+	c.p.pos = append(c.p.pos, pcPos{len(c.p.code), row, col})
+}
+
+// compile compiles n, leaving its value on the stack. tail is true when n
+// is in tail position, so that a call can replace the current frame. It
+// fails at the innermost node whose instructions have an operand too large
+// for them.
+func (c *compiler) compile(n node, tail bool) error {
+	if err := c.compileNode(n, tail); err != nil {
+		return err
+	}
+	if c.overflow {
+		row, col := n.pos()
+		return &Error{Row: row, Col: col, Msg: "Expression too large to compile"}
+	}
+	return nil
+}
+
+func (c *compiler) compileNode(n node, tail bool) error {
+	switch x := n.(type) {
+	case *constNode:
+		c.emit(opConst, c.constant(x.v))
+	case *localNode:
+		c.mark(x)
+		c.emit(opLocal, x.depth, x.index)
+	case *globalNode:
+		c.mark(x)
+		c.emit(opGlobal, c.constant(x))
+	case *setNode:
+		if err := c.compile(x.value, false); err != nil {
+			return err
+		}
+		c.mark(x.target)
+		switch t := x.target.(type) {
+		case *localNode:
+			c.emit(opSetLocal, t.depth, t.index)
+		case *globalNode:
+			c.emit(opSetGlobal, c.constant(t))
+		}
+	case *defineNode:
+		if err := c.compile(x.value, false); err != nil {
+			return err
+		}
+		switch t := x.target.(type) {
+		case *localNode:
+			c.emit(opDefLocal, t.index)
+		case *globalNode:
+			c.emit(opDefGlobal, c.constant(t))
+		}
+	case *ifNode:
+		if err := c.compile(x.test, false); err != nil {
+			return err
+		}
+		jf := c.emit(opJumpIfFalse, 0)
+		if err := c.compile(x.then, tail); err != nil {
+			return err
+		}
+		j := c.emit(opJump, 0)
+		c.patch(jf)
+		if err := c.compile(x.els, tail); err != nil {
+			return err
+		}
+		c.patch(j)
+	case *switchNode:
+		return c.compileSwitch(x, tail)
+	case *andNode:
+		return c.compileLogic(x.exprs, true, tail)
+	case *orNode:
+		return c.compileLogic(x.exprs, false, tail)
+	case *seqNode:
+		for i, b := range x.body {
+			last := i == len(x.body)-1
+			if err := c.compile(b, tail && last); err != nil {
+				return err
+			}
+			if !last {
+				c.emit(opPop)
+			}
+		}
+	case *lambdaNode:
+		p, err := compileLambda(x)
+		if err != nil {
+			return err
+		}
+		c.emit(opClosure, c.constant(p))
+	case *letNode:
+		for _, v := range x.values {
+			if err := c.compile(v, false); err != nil {
+				return err
+			}
+		}
+		c.emit(opPushEnv, len(x.values), c.constant(x.names))
+		if err := c.compile(x.body, tail); err != nil {
+			return err
+		}
+		c.emit(opPopEnv)
+	case *callNode:
+		if err := c.compile(x.f, false); err != nil {
+			return err
+		}
+		for _, a := range x.args {
+			if err := c.compile(a, false); err != nil {
+				return err
+			}
+		}
+		c.mark(x)
+		if tail {
+			c.emit(opTailCall, len(x.args))
+		} else {
+			c.emit(opCall, len(x.args))
+		}
+	default:
+		return fmt.Errorf("Unexpected node %T", n)
+	}
+	return nil
+}
+
+// compileSwitch keeps the key, if any, on the stack while the matches are
+// compared against it.
+func (c *compiler) compileSwitch(x *switchNode, tail bool) error {
+	keyed := x.key != nil
+	if keyed {
+		if err := c.compile(x.key, false); err != nil {
+			return err
+		}
+	}
+	var ends []int
+	for i, t := range x.tests {
+		if keyed {
+			c.emit(opDup)
+		}
+		if err := c.compile(t, false); err != nil {
+			return err
+		}
+		if keyed {
+			c.emit(opEqual)
+		}
+		next := c.emit(opJumpIfFalse, 0)
+		if keyed {
+			c.emit(opPop)
+		}
+		if err := c.compile(x.thens[i], tail); err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(opJump, 0))
+		c.patch(next)
+	}
+	if keyed {
+		c.emit(opPop)
+	}
+	if err := c.compile(x.def, tail); err != nil {
+		return err
+	}
+	for _, j := range ends {
+		c.patch(j)
+	}
+	return nil
+}
+
+// compileLogic compiles and, which stops at the first false value, or or,
+// which stops at the first true one. The value that stops evaluation is
+// left on the stack as the result.
+func (c *compiler) compileLogic(exprs []node, and bool, tail bool) error {
+	var ends []int
+	for _, x := range exprs[:len(exprs)-1] {
+		if err := c.compile(x, false); err != nil {
+			return err
+		}
+		c.emit(opDup)
+		if and {
+			ends = append(ends, c.emit(opJumpIfFalse, 0))
+		} else {
+			next := c.emit(opJumpIfFalse, 0)
+			ends = append(ends, c.emit(opJump, 0))
+			c.patch(next)
+		}
+		c.emit(opPop)
+	}
+	if err := c.compile(exprs[len(exprs)-1], tail); err != nil {
+		return err
+	}
+	for _, j := range ends {
+		c.patch(j)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package lisp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// docs documents the special forms and constants. Each starts with how the
+// form is used, on a line of its own, as the Doc of a Builtin does.
+var docs = map[string]string{
+	"quote":  "(quote datum)\nReturns datum without evaluating it. 'datum is short for (quote datum).",
+	"if":     "(if test then [else])\nEvaluates then if test is true, otherwise else, which defaults to ().",
+	"switch": "(switch (key) match value ... [default])\n(switch () test value ... [default])\nReturns the value of the first clause whose match is equal to key, or whose test is true. A trailing odd expression is the default.",
+	"do":     "(do expr ...)\nEvaluates each expr in turn, returning the value of the last.",
+	"and":    "(and expr ...)\nEvaluates each expr until one is false, returning the value of the last evaluated, or #t if there are none.",
+	"or":     "(or expr ...)\nEvaluates each expr until one is true, returning the value of the last evaluated, or #f if there are none.",
+	"define": "(define name value)\n(define (name params ...) [docstring] body ...)\nDefines name in the current scope, as a procedure in the second form.",
+	"set!":   "(set! name value)\nAssigns value to the existing variable name.",
+	"lambda": "(lambda (params ...) [docstring] body ...)\n(lambda (params ... . rest) [docstring] body ...)\n(lambda rest [docstring] body ...)\nReturns a procedure. A rest parameter collects any remaining arguments as a list. A string before the rest of the body documents the procedure.",
+	"let":    "(let ((name value) ...) body ...)\nEvaluates body with each name bound to its value.",
+	"module": "(module name (export name ...))\nStarts a module, a file evaluated by import in a global environment of its own, naming the variables it exports.",
+	"import": "(import file [:as alias])\nEvaluates the module in file, unless it has been already, and binds alias:name to each name it exports. The alias defaults to the name of the module. The file is looked for as by load.",
+
+	"true":  "true\nThe constant #t.",
+	"false": "false\nThe constant #f.",
+	"nil":   "nil\nThe empty list ().",
+}
+
+// Doc returns the documentation of the special form, builtin, constant or
+// procedure of the standard prelude name. The first lines show how it is
+// called.
+func Doc(name string) (string, bool) {
+	if d, ok := docs[name]; ok {
+		return d, true
+	}
+	if b, ok := builtinIndex[name]; ok {
+		return b.Doc, true
+	}
+	return preludeDoc(name)
+}
+
+// builtinIndex holds the builtins by name.
+var builtinIndex = func() map[string]*Builtin {
+	m := map[string]*Builtin{}
+	for _, bs := range builtinTables {
+		for _, b := range bs {
+			m[b.Name] = b
+		}
+	}
+	return m
+}()
+
+// docNames returns the names Doc documents, sorted.
+func docNames() []string {
+	var names []string
+	for name := range docs {
+		names = append(names, name)
+	}
+	for name := range builtinIndex {
+		names = append(names, name)
+	}
+	preludeDoc("")
+	for name := range preludeDocs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Doc returns the documentation of name: that of a special form or
+// constant, or of the procedure it is bound to, which is the docstring
+// of a lambda after how it is called. A procedure without a docstring
+// has none.
+func (in *Interpreter) Doc(name string) (string, bool) {
+	if d, ok := docs[name]; ok {
+		return d, true
+	}
+	return procDoc(in.global[Intern(name)])
+}
+
+// procDoc returns the documentation of the procedure f.
+func procDoc(f Value) (string, bool) {
+	var (
+		n    *lambdaNode
+		name string
+	)
+	switch f := f.(type) {
+	case *Builtin:
+		return f.Doc, f.Doc != ""
+	case *Lambda:
+		n, name = f.node, f.displayName()
+	case *Closure:
+		n, name = f.proto.lambda, f.displayName()
+	}
+	if n == nil || n.doc == "" {
+		return "", false
+	}
+	return n.signature(name) + "\n" + n.doc, true
+}
+
+func builtinDoc(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("doc", args, 1, 1); err != nil {
+		return nil, err
+	}
+	var (
+		d  string
+		ok bool
+	)
+	switch f := args[0].(type) {
+	case *Symbol:
+		d, ok = in.Doc(f.Name)
+	case *Builtin, *Lambda, *Closure:
+		d, ok = procDoc(f)
+	default:
+		return nil, fmt.Errorf("doc expects a procedure or symbol, got %s", String(args[0]))
+	}
+	if !ok {
+		return false, nil
+	}
+	return d, nil
+}
+
+func builtinApropos(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("apropos", args, 1, 1); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("apropos expects a string, got %s", String(args[0]))
+	}
+	s = strings.ToLower(s)
+	names := in.Names()
+	for name := range docs {
+		if specialForm(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var found []Value
+	for _, name := range names {
+		d, _ := in.Doc(name)
+		if strings.Contains(strings.ToLower(name), s) || strings.Contains(strings.ToLower(d), s) {
+			found = append(found, Intern(name))
+		}
+	}
+	return List(found...), nil
+}
+
+// specialForm reports whether name is a special form rather than a
+// builtin.
+func specialForm(name string) bool {
+	switch name {
+	case "quote", "if", "switch", "do", "and", "or", "define", "set!", "lambda", "let", "module", "import":
+		return true
+	}
+	return false
+}
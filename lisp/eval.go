@@ -0,0 +1,441 @@
+package lisp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is an error raised while evaluating an expression, positioned at
+// the start of the expression.
+type Error struct {
+	// File names the file the expression is in, or is "" for code that
+	// was not read from a file
+	File     string
+	Row, Col int
+	Msg      string
+	// Trace lists the calls that were active when the error was raised,
+	// innermost first, as "name row:col" for each call site. Only the
+	// innermost maxTrace calls are kept; Elided counts the rest.
+	Trace  []string
+	Elided int
+	// Warning marks a problem Vet reports that would not stop the program
+	// running, unlike those Check reports
+	Warning bool
+}
+
+const maxTrace = 10
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File + ":")
+		if e.Row == 0 {
+			b.WriteString(" ")
+		}
+	}
+	if e.Row != 0 {
+		fmt.Fprintf(&b, "%d:%d: ", e.Row, e.Col)
+	}
+	b.WriteString(e.Msg)
+	for _, t := range e.Trace {
+		b.WriteString("\n\tin " + t)
+	}
+	if e.Elided > 0 {
+		fmt.Fprintf(&b, "\n\t... %d more", e.Elided)
+	}
+	return b.String()
+}
+
+// addCall records a call to the procedure name at row:col in the trace of
+// err.
+func addCall(err error, name string, row, col int) {
+	le, ok := err.(*Error)
+	if !ok {
+		return
+	}
+	if len(le.Trace) == maxTrace {
+		le.Elided++
+		return
+	}
+	le.Trace = append(le.Trace, fmt.Sprintf("%s %d:%d", name, row, col))
+}
+
+// setFile records that err was raised by code in the file named file,
+// unless the file of err is known already.
+func setFile(err error, file string) {
+	switch e := err.(type) {
+	case *Error:
+		if e.File == "" {
+			e.File = file
+		}
+	case ErrorList:
+		for _, x := range e {
+			setFile(x, file)
+		}
+	}
+}
+
+// errorf returns an *Error positioned at e.
+func errorf(e *expr, format string, a ...interface{}) *Error {
+	err := &Error{Msg: fmt.Sprintf(format, a...)}
+	if e != nil {
+		err.Row, err.Col = e.pos()
+	}
+	return err
+}
+
+// eval evaluates n in the frame fr. Expressions in tail position are
+// evaluated by looping rather than recursing, so tail calls run in
+// constant Go stack.
+func (in *Interpreter) eval(n node, fr *frame) (v Value, err error) {
+	// The lambda whose body is being evaluated, and the call that entered it
+	var (
+		proc *Lambda
+		call node
+	)
+	in.depth++
+	defer func() {
+		in.depth--
+		if err != nil && proc != nil {
+			setFile(err, proc.node.file)
+			row, col := call.pos()
+			addCall(err, proc.displayName(), row, col)
+		}
+	}()
+	if in.depth > in.maxDepth {
+		row, col := n.pos()
+		return nil, &Error{Row: row, Col: col, Msg: fmt.Sprintf("Maximum evaluation depth %d exceeded", in.maxDepth)}
+	}
+	for {
+		switch x := n.(type) {
+		case *constNode:
+			return x.v, nil
+		case *localNode:
+			return x.lookup(fr)
+		case *globalNode:
+			return in.lookup(x)
+		case *setNode:
+			v, err := in.eval(x.value, fr)
+			if err != nil {
+				return nil, err
+			}
+			return v, in.assign(x.target, fr, v)
+		case *defineNode:
+			v, err := in.eval(x.value, fr)
+			if err != nil {
+				return nil, err
+			}
+			switch t := x.target.(type) {
+			case *localNode:
+				fr.vals[t.index] = nameProcedure(v, t.sym.Name)
+				return t.sym, nil
+			case *globalNode:
+				t.env[t.sym] = nameProcedure(v, t.sym.Name)
+				return t.sym, nil
+			}
+		case *ifNode:
+			t, err := in.eval(x.test, fr)
+			if err != nil {
+				return nil, err
+			}
+			if truthy(t) {
+				n = x.then
+			} else {
+				n = x.els
+			}
+		case *switchNode:
+			var key Value
+			if x.key != nil {
+				if key, err = in.eval(x.key, fr); err != nil {
+					return nil, err
+				}
+			}
+			n = x.def
+			for i, tn := range x.tests {
+				t, err := in.eval(tn, fr)
+				if err != nil {
+					return nil, err
+				}
+				if x.key != nil && equal(key, t) || x.key == nil && truthy(t) {
+					n = x.thens[i]
+					break
+				}
+			}
+		case *andNode:
+			for _, b := range x.exprs[:len(x.exprs)-1] {
+				v, err := in.eval(b, fr)
+				if err != nil || !truthy(v) {
+					return v, err
+				}
+			}
+			n = x.exprs[len(x.exprs)-1]
+		case *orNode:
+			for _, b := range x.exprs[:len(x.exprs)-1] {
+				v, err := in.eval(b, fr)
+				if err != nil || truthy(v) {
+					return v, err
+				}
+			}
+			n = x.exprs[len(x.exprs)-1]
+		case *seqNode:
+			for _, b := range x.body[:len(x.body)-1] {
+				if _, err := in.eval(b, fr); err != nil {
+					return nil, err
+				}
+			}
+			n = x.body[len(x.body)-1]
+		case *lambdaNode:
+			return &Lambda{node: x, env: fr, name: x.name}, nil
+		case *letNode:
+			vals := make([]Value, len(x.names))
+			for i, vn := range x.values {
+				if vals[i], err = in.eval(vn, fr); err != nil {
+					return nil, err
+				}
+			}
+			for i := len(x.values); i < len(vals); i++ {
+				vals[i] = unset{}
+			}
+			fr = &frame{vals: vals, names: x.names, up: fr}
+			n = x.body
+		case *callNode:
+			f, err := in.eval(x.f, fr)
+			if err != nil {
+				return nil, err
+			}
+			args := make([]Value, len(x.args))
+			for i, a := range x.args {
+				if args[i], err = in.eval(a, fr); err != nil {
+					return nil, err
+				}
+			}
+			l, ok := f.(*Lambda)
+			if !ok {
+				v, err := in.apply(f, args)
+				if err != nil {
+					return nil, position(x, err)
+				}
+				return v, nil
+			}
+			lf, err := l.bind(args)
+			if err != nil {
+				return nil, position(x, err)
+			}
+			proc, call = l, x
+			n, fr = l.node.body, lf
+		default:
+			return nil, fmt.Errorf("Unexpected node %T", n)
+		}
+	}
+}
+
+// position positions err at n unless it already carries a position.
+func position(n node, err error) error {
+	switch err.(type) {
+	case *Error, ErrorList:
+		return err
+	}
+	row, col := n.pos()
+	return &Error{Row: row, Col: col, Msg: err.Error()}
+}
+
+func (n *localNode) lookup(fr *frame) (Value, error) {
+	for d := n.depth; d > 0; d-- {
+		fr = fr.up
+	}
+	v := fr.vals[n.index]
+	if _, ok := v.(unset); ok {
+		return nil, position(n, fmt.Errorf("Unbound variable %s", n.sym))
+	}
+	return v, nil
+}
+
+func (in *Interpreter) lookup(n *globalNode) (Value, error) {
+	v, ok := n.env[n.sym]
+	if !ok {
+		return nil, position(n, fmt.Errorf("Unbound variable %s", n.sym))
+	}
+	return v, nil
+}
+
+// assign sets the existing variable target to v.
+func (in *Interpreter) assign(target node, fr *frame, v Value) error {
+	switch t := target.(type) {
+	case *localNode:
+		if _, err := t.lookup(fr); err != nil {
+			return err
+		}
+		for d := t.depth; d > 0; d-- {
+			fr = fr.up
+		}
+		fr.vals[t.index] = v
+	case *globalNode:
+		if _, err := in.lookup(t); err != nil {
+			return err
+		}
+		t.env[t.sym] = v
+	}
+	return nil
+}
+
+// nameProcedure names v after the variable it is defined as if it is an
+// anonymous procedure.
+func nameProcedure(v Value, name string) Value {
+	switch f := v.(type) {
+	case *Lambda:
+		if f.name == "" {
+			f.name = name
+		}
+	case *Closure:
+		if f.name == "" {
+			f.name = name
+		}
+	}
+	return v
+}
+
+// evalBody evaluates the nodes of a program in order, returning the value
+// of the last.
+func (in *Interpreter) evalBody(ns []node) (Value, error) {
+	var v Value
+	for _, n := range ns {
+		var err error
+		if v, err = in.eval(n, nil); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (in *Interpreter) apply(f Value, args []Value) (Value, error) {
+	switch f := f.(type) {
+	case *Builtin:
+		return f.Fn(in, args)
+	case *Lambda:
+		fr, err := f.bind(args)
+		if err != nil {
+			return nil, err
+		}
+		v, err := in.eval(f.node.body, fr)
+		if err != nil {
+			setFile(err, f.node.file)
+		}
+		return v, err
+	case *Closure:
+		return in.callClosure(f, args)
+	}
+	return nil, fmt.Errorf("%s is not a procedure", String(f))
+}
+
+// bind returns a new frame with f's parameters bound to args.
+func (f *Lambda) bind(args []Value) (*frame, error) {
+	vals, err := bindArgs(f, f.node, args)
+	if err != nil {
+		return nil, err
+	}
+	return &frame{vals: vals, names: f.node.names, up: f.env}, nil
+}
+
+// bindArgs returns the initial variables of a call of the procedure f
+// defined by n.
+func bindArgs(f Value, n *lambdaNode, args []Value) ([]Value, error) {
+	vals := make([]Value, len(n.names))
+	if n.rest {
+		req := n.nparams - 1
+		if len(args) < req {
+			return nil, fmt.Errorf("%s expects at least %d arguments, got %d", f, req, len(args))
+		}
+		copy(vals, args[:req])
+		vals[req] = List(args[req:]...)
+	} else {
+		if len(args) != n.nparams {
+			return nil, fmt.Errorf("%s expects %d arguments, got %d", f, n.nparams, len(args))
+		}
+		copy(vals, args)
+	}
+	for i := n.nparams; i < len(vals); i++ {
+		vals[i] = unset{}
+	}
+	return vals, nil
+}
+
+func (f *Lambda) String() string {
+	if f.name == "" {
+		return "#<lambda>"
+	}
+	return "#<lambda " + f.name + ">"
+}
+
+func (f *Lambda) displayName() string {
+	if f.name == "" {
+		return "lambda"
+	}
+	return f.name
+}
+
+// quote converts e to the data it denotes.
+func quote(e *expr) Value {
+	return quoter{}.quote(e)
+}
+
+// A quoter holds the values of the datum labels met so far. Pairs, vectors
+// and hash tables are labelled before their elements are converted, so
+// that references within them make cycles.
+type quoter map[int]Value
+
+func (q quoter) quote(e *expr) Value {
+	if !e.isList() {
+		var v Value
+		switch e.atom.typ {
+		case tokenAtom:
+			v = e.sym()
+		case tokenLabelRef:
+			v = q[e.atom.val.(int)]
+		default:
+			v = e.atom.val
+		}
+		q.define(e, v)
+		return v
+	}
+	items := e.items()
+	switch e.lit {
+	case tokenVector:
+		vec := &Vector{make([]Value, len(items))}
+		q.define(e, vec)
+		for i, x := range items {
+			vec.Items[i] = q.quote(x)
+		}
+		return vec
+	case tokenHash:
+		h := NewHash()
+		q.define(e, h)
+		for _, kv := range items {
+			h.Set(q.quote(kv.first), q.quote(kv.tail))
+		}
+		return h
+	}
+	if len(items) == 0 {
+		q.define(e, nil)
+		return nil
+	}
+	head := &Pair{}
+	q.define(e, head)
+	p := head
+	for i, x := range items {
+		p.Car = q.quote(x)
+		if i < len(items)-1 {
+			next := &Pair{}
+			p.Cdr, p = next, next
+		}
+	}
+	if t := e.dotted(); t != nil {
+		p.Cdr = q.quote(t)
+	}
+	return head
+}
+
+func (q quoter) define(e *expr, v Value) {
+	if e.labelled {
+		q[e.label] = v
+	}
+}
@@ -0,0 +1,467 @@
+package lisp
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type evalData struct {
+	test     string
+	expected string // Printed result, or the error message
+}
+
+func TestArith(t *testing.T) {
+	tests := []evalData{
+		{`(+ 1 2 3)`, "6"},
+		{`(+ 1 2.5)`, "3.5"},
+		{`(- 5)`, "-5"},
+		{`(- 10 1 2)`, "7"},
+		{`(* 2 3.0)`, "6.0"},
+		{`(/ 6 2)`, "3"},
+		{`(/ 7 2)`, "3.5"},
+		{`(/ 1 0)`, "1:1: /: division by zero"},
+		{`(mod 7 3)`, "1"},
+		{`(< 1 2 3)`, "#t"},
+		{`(< 1 3 2)`, "#f"},
+		{`(>= 2 2.0)`, "#t"},
+		{`(+ 1 'a)`, "1:1: + expects numbers, got a"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSpecialForms(t *testing.T) {
+	tests := []evalData{
+		{`'(a (b 1) ())`, "(a (b 1) ())"},
+		{`(if (< 1 2) 'yes 'no)`, "yes"},
+		{`(if (< 2 1) 'yes)`, "()"},
+		{`(if () 'yes 'no)`, "no"},
+		{`(define x 3) (switch (x) 2 'two 3 'three)`, "three"},
+		{`(define x 9) (switch (x) 2 'two 3 'three 'other)`, "other"},
+		{`(define x 4) (switch () (< x 2) 'small (< x 5) 'medium)`, "medium"},
+		{`(do 1 2 3)`, "3"},
+		{`(define x 1) (set! x (+ x 1)) x`, "2"},
+		{`(set! y 1)`, "1:7: Unbound variable y"},
+		{`(let ((a 1) (b 2)) (+ a b))`, "3"},
+		{`((lambda (a b) (* a b)) 3 4)`, "12"},
+		{`((lambda args args) 1 2)`, "(1 2)"},
+		{`((lambda (a) a))`, "1:1: #<lambda> expects 1 arguments, got 0"},
+		{`(define (fact n) (if (< n 2) 1 (* n (fact (- n 1))))) (fact 10)`, "3628800"},
+		{`(define (counter) (define n 0) (lambda () (set! n (+ n 1)) n))
+		  (define c (counter)) (c) (c)`, "2"},
+		{`(define (f) (define (g) (h)) (define (h) 7) (g)) (f)`, "7"},
+		{`(define (f) (g) (define (g) 1)) (f)`, "1:14: Unbound variable g\n\tin f 1:33"},
+		{`(let ((a 1)) (define b (+ a 1)) (set! a 5) (+ a b))`, "7"},
+		{`(let ((a 1) (b)) a)`, "1:13: let expects bindings of the form (name value)"},
+		{`(define f (lambda (x) x)) f`, "#<lambda f>"},
+		{`(if (< 1 2) 1 (if))`, "1:15: if expects 2 or 3 arguments, got 0"},
+		{`(foo 1)`, "1:2: Unbound variable foo"},
+		{`(1 2)`, "1:1: 1 is not a procedure"},
+		{`(if 1)`, "1:1: if expects 2 or 3 arguments, got 1"},
+		{`(define (f x) (error "bad value:" x "!")) (f 'y)`, "1:15: bad value: y \"!\"\n\tin f 1:43"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	tests := []evalData{
+		{`(eq? 'abc 'abc)`, "#t"},
+		{`(eq? 'abc 'abd)`, "#f"},
+		{`(eq? '(a) '(a))`, "#f"},
+		{`(symbol->string 'abc)`, `"abc"`},
+		{`(eq? (string->symbol (symbol->string 'abc)) 'abc)`, "#t"},
+		{`(eq? (intern (symbol->string 'x)) (define x 1))`, "#t"},
+		{`(symbol? 'a)`, "#t"},
+		{`(symbol? 1)`, "#f"},
+		{`(symbol->string 1)`, "1:1: symbol->string expects a symbol, got 1"},
+		{`(intern 'a)`, "1:1: intern expects a string, got a"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+	if Intern("abc") != Intern("abc") || Intern("abc") == Intern("abd") {
+		t.Errorf("Intern does not return unique symbols")
+	}
+	// Symbols are shared between interpreters
+	v1, _ := New(nil).EvalString(`'shared`)
+	v2, _ := New(nil).EvalString(`'shared`)
+	if v1 != v2 || v1 != Intern("shared") {
+		t.Errorf("Expected the same symbol, got %p and %p", v1, v2)
+	}
+	in := New(nil)
+	in.EvalString(`(define zz-last 1)`)
+	names := in.Names()
+	if !sort.StringsAreSorted(names) || names[len(names)-1] != "zz-last" || len(names) != len(in.global) {
+		t.Errorf("Unexpected names %v", names)
+	}
+	if v, ok := in.Lookup("zz-last"); !ok || v != 1 {
+		t.Errorf("Expected zz-last to be 1, got %v %v", v, ok)
+	}
+	if _, ok := in.Lookup("zz-none"); ok {
+		t.Errorf("Expected zz-none to be unbound")
+	}
+}
+
+func TestLists(t *testing.T) {
+	tests := []evalData{
+		{`'(a . b)`, "(a . b)"},
+		{`'(a b . (c d))`, "(a b c d)"},
+		{`'(1 (2 . 3) . 4)`, "(1 (2 . 3) . 4)"},
+		{`(cons 1 2)`, "(1 . 2)"},
+		{`(cons 1 '(2))`, "(1 2)"},
+		{`(car '(a . b))`, "a"},
+		{`(cdr '(a . b))`, "b"},
+		{`(car '())`, "1:1: car expects a pair, got ()"},
+		{`(pair? '(a))`, "#t"},
+		{`(list 1 (+ 1 1) 'c)`, "(1 2 c)"},
+		{`(length '(1 2 3))`, "3"},
+		{`(length '(1 . 2))`, "1:1: length expects a list, got (1 . 2)"},
+		{`(append '(1 2) '() '(3) 4)`, "(1 2 3 . 4)"},
+		{`(append)`, "()"},
+		{`(reverse '(1 2 3))`, "(3 2 1)"},
+		{`(map (lambda (x) (* x x)) '(1 2 3))`, "(1 4 9)"},
+		{`(map + '(1 2 3) '(10 20))`, "(11 22)"},
+		{`(filter (lambda (x) (> x 1)) '(1 2 3))`, "(2 3)"},
+		{`(fold cons '() '(1 2 3))`, "(((() . 1) . 2) . 3)"},
+		{`(reduce + '(1 2 3 4))`, "10"},
+		{`(reduce + '())`, "1:1: reduce expects a non-empty list"},
+		{`(assoc 'b '((a 1) (b 2)))`, "(b 2)"},
+		{`(assoc 'c '((a 1) (b 2)))`, "#f"},
+		{`(member 2 '(1 2 3))`, "(2 3)"},
+		{`(nth 1 '(a b c))`, "b"},
+		{`(nth 3 '(a b c))`, "1:1: nth: index 3 out of range for list of length 3"},
+		{`(last '(a b c))`, "c"},
+		{`(sort '(3 1 2))`, "(1 2 3)"},
+		{`(sort '((b 2) (a 1) (c 1)) (lambda (x y) (< (nth 1 x) (nth 1 y))))`, "((a 1) (c 1) (b 2))"},
+		{`(sort '(1 a))`, "1:1: sort: cannot compare a and 1"},
+		{`(map (lambda (x) (car x)) '((1) 2))`, "1:18: car expects a pair, got 2"},
+		{`(define (f a . rest) (list a rest)) (list (f 1) (f 1 2 3))`, "((1 ()) (1 (2 3)))"},
+		{`(define (f . rest) rest) (f 1 2)`, "(1 2)"},
+		{`((lambda (a b . c) c))`, "1:1: #<lambda> expects at least 2 arguments, got 0"},
+		{`(lambda (a . 1) a)`, "1:14: Invalid parameter"},
+		{`(+ 1 . 2)`, "1:1: Cannot evaluate an improper list"},
+		// Cyclic lists
+		{`(length '#0=(1 2 . #0#))`, "1:1: length expects a list, got the cyclic list #0=(1 2 . #0#)"},
+		{`(length '#0=(1 . #0#))`, "1:1: length expects a list, got the cyclic list #0=(1 . #0#)"},
+		{`(map car '(1 2 3 . #0=((4) . #0#)))`, "1:1: map expects a list, got the cyclic list (1 2 3 . #0=((4) . #0#))"},
+		{`(define l (list 1 2 3)) (set-cdr! (cddr l) l) (fold + 0 l)`, "1:47: fold expects a list, got the cyclic list #0=(1 2 3 . #0#)"},
+		{`(equal? '#0=(1 2 . #0#) '#1=(1 2 1 2 . #1#))`, "#t"},
+		{`(equal? '#0=(1 2 . #0#) '#1=(1 3 . #1#))`, "#f"},
+		{`(equal? '#0=(1 2 . #0#) '(1 2 1 2))`, "#f"},
+		{`(equal? '#0=(#0# . #0#) '#1=(#1# . #1#))`, "#t"},
+		{`(equal? '#0=#(1 #0#) '#1=#(1 #1#))`, "#t"},
+		{`(equal? '#0=#(1 #0#) '#1=#(2 #1#))`, "#f"},
+		{`(member '#0=(1 . #0#) '(2 #1=(1 1 . #1#)))`, "(#0=(1 1 . #0#))"},
+		{`(equal? (range 0 100000) (range 0 100000))`, "#t"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []evalData{
+		{`"a\tb"`, `"a\tb"`},
+		{`(string? "a")`, "#t"},
+		{`(string-length "héllo")`, "5"},
+		{`(substring "héllo" 1 3)`, `"él"`},
+		{`(substring "héllo" 2)`, `"llo"`},
+		{`(substring "abc" 2 1)`, "1:1: substring: end 1 is before start 2"},
+		{`(substring "abc" 4)`, "1:1: substring: index 4 out of range"},
+		{`(string-append "a" "é" "c")`, `"aéc"`},
+		{`(string-append "a" 1)`, "1:1: string-append expects a string, got 1"},
+		{`(string-split " a  b c ")`, `("a" "b" "c")`},
+		{`(string-split "a,b,,c" ",")`, `("a" "b" "" "c")`},
+		{`(string-join '("a" "b" "c") ", ")`, `"a, b, c"`},
+		{`(string-join '("a" "b"))`, `"ab"`},
+		{`(string-index "héllo" "l")`, "2"},
+		{`(string-index "hello" "z")`, "#f"},
+		{`(string-upcase "héllo")`, `"HÉLLO"`},
+		{`(string-downcase "ÀB")`, `"àb"`},
+		{`(string-trim "  a b ")`, `"a b"`},
+		{`(string-trim "xxaxx" "x")`, `"a"`},
+		{`(string-replace "a-b-c" "-" "+")`, `"a+b+c"`},
+		{`(string->number "42")`, "42"},
+		{`(string->number "2.5")`, "2.5"},
+		{`(string->number "x")`, "#f"},
+		{`(number->string 42)`, `"42"`},
+		{`(string-rune "héllo" 1)`, "233"},
+		{`(string-rune "" 0)`, "1:1: string-rune: index 0 out of range"},
+		{`(string->runes "hé")`, "(104 233)"},
+		{`(runes->string '(104 233))`, `"hé"`},
+		{`(runes->string '(-1))`, "1:1: runes->string expects code points, got -1"},
+		{`(runes->string '(4294967361))`, "1:1: runes->string expects code points, got 4294967361"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChars(t *testing.T) {
+	tests := []evalData{
+		{`#\a`, `#\a`},
+		{`'(#\space #\newline #\tab #\x41 #\x7)`, `(#\space #\newline #\tab #\A #\alarm)`},
+		{`#\x1`, `#\x1`},
+		{`(char? #\a)`, "#t"},
+		{`(char? "a")`, "#f"},
+		{`(char->integer #\é)`, "233"},
+		{`(integer->char 955)`, `#\λ`},
+		{`(integer->char -1)`, "1:1: integer->char expects a code point, got -1"},
+		{`(integer->char 4294967361)`, "1:1: integer->char expects a code point, got 4294967361"},
+		{`(integer->char 55296)`, "1:1: integer->char expects a code point, got 55296"},
+		{`(eq? #\a (string-ref "cat" 1))`, "#t"},
+		{`(char-alphabetic? #\λ)`, "#t"},
+		{`(char-numeric? #\7)`, "#t"},
+		{`(char-whitespace? #\tab)`, "#t"},
+		{`(char-upper-case? #\a)`, "#f"},
+		{`(char-lower-case? #\a)`, "#t"},
+		{`(char-upcase #\é)`, `#\É`},
+		{`(char-downcase 1)`, "1:1: char-downcase expects a character, got 1"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	tests := []evalData{
+		{`#(1 (+ 1 1) "s")`, `#(1 (+ 1 1) "s")`},
+		{`#()`, "#()"},
+		{`(vector 1 (+ 1 1))`, "#(1 2)"},
+		{`(make-vector 2 'x)`, "#(x x)"},
+		{`(vector? #(1))`, "#t"},
+		{`(vector-length #(1 2 3))`, "3"},
+		{`(vector-ref #(a b c) 2)`, "c"},
+		{`(vector-ref #(a b c) 3)`, "1:1: vector-ref: index 3 out of range"},
+		{`(define v (make-vector 3 0)) (vector-set! v 1 'x) v`, "#(0 x 0)"},
+		{`(vector->list #(1 2))`, "(1 2)"},
+		{`(list->vector '(1 2))`, "#(1 2)"},
+		{`(equal? #(1 (2)) (vector 1 '(2)))`, "#t"},
+		{`#hash((a . 1) ("b" . #\c))`, `#hash((a . 1) ("b" . #\c))`},
+		{`(define h (make-hash)) (hash-set! h 'a 1) (hash-set! h 2 'b) (hash-set! h 'a 3) h`, "#hash((a . 3) (2 . b))"},
+		{`(hash-ref #hash((a . 1)) 'a)`, "1"},
+		{`(hash-ref #hash((a . 1)) 'b 0)`, "0"},
+		{`(hash-ref #hash((a . 1)) 'b)`, "1:1: hash-ref: no value for key b"},
+		{`(hash-ref (make-hash '(("k" . v))) "k")`, "v"},
+		{`(hash-has-key? #hash((#\a . 1)) #\a)`, "#t"},
+		{`(define h (make-hash '((a . 1) (b . 2) (c . 3)))) (hash-remove! h 'b) (list (hash-keys h) (hash-values h) (hash-count h))`, "((a c) (1 3) 2)"},
+		{`(hash->list #hash((a . 1)))`, "((a . 1))"},
+		{`(define n 0) (hash-for-each #hash((a . 1) (b . 2)) (lambda (k v) (set! n (+ n v)))) n`, "3"},
+		{`(equal? #hash((a . 1) (b . 2)) #hash((b . 2) (a . 1)))`, "#t"},
+		{`(hash-ref 1 2)`, "1:1: hash-ref expects a hash table, got 1"},
+		{`#hash(a)`, "1:7: Expecting (key . value) in #hash"},
+		{`#(a . b)`, "1:5: Unexpected '.'"},
+		{`#hush(a)`, "1:1: Unexpected token[#hush]"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+	// Printed collections read back as equal values
+	in := New(nil)
+	v, err := in.EvalString(`(define h (make-hash)) (hash-set! h "k\n" #(1 2.5 #\space (a . b))) h`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := in.EvalString("'" + String(v))
+	if err != nil || !equal(v, w) {
+		t.Errorf("Expected %s to read back, got %v, %v", String(v), String(w), err)
+	}
+}
+
+func TestBooleans(t *testing.T) {
+	tests := []evalData{
+		{`(list #t #f #true #false true false nil)`, "(#t #f #t #f #t #f ())"},
+		{`(map (lambda (x) (if x 'yes 'no)) '(#f () 0 "" #() a))`, "(no no yes yes yes yes)"},
+		{`(list (null? '()) (null? nil) (null? #f) (null? '(a)))`, "(#t #t #f #f)"},
+		{`(list (boolean? #f) (boolean? '()))`, "(#t #f)"},
+		{`(list (not #f) (not '()) (not 0))`, "(#t #t #f)"},
+		{`(list (and) (and 1 2) (and 1 '() 2) (and #f (car '())))`, "(#t 2 () #f)"},
+		{`(list (or) (or #f 2) (or '() #f) (or 1 (car '())))`, "(#f 2 #f 1)"},
+		{`(define (loop n) (and (> n 0) (or (= n 1) (loop (- n 1))))) (loop 100000)`, "#t"},
+		{`(and 1 (car 1))`, "1:8: car expects a pair, got 1"},
+		{`#maybe`, "1:1: Unexpected token[#maybe]"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// Without tail calls these loops need far more than 1MB of Go stack,
+	// and exceeding it is a fatal error rather than a test failure.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	tests := []evalData{
+		{`(define (loop n) (if (= n 0) 'done (loop (- n 1)))) (loop 100000)`, "done"},
+		{`(define (even n) (if (= n 0) 'even (odd (- n 1))))
+		  (define (odd n) (if (= n 0) 'odd (even (- n 1))))
+		  (even 100001)`, "odd"},
+		{`(define (loop n) (switch () (= n 0) 'done (loop (- n 1)))) (loop 100000)`, "done"},
+		{`(define (loop n) (switch (n) 0 'done (do n (let ((m (- n 1))) (loop m))))) (loop 100000)`, "done"},
+		{`(define (sum n acc) (if (= n 0) acc (sum (- n 1) (+ acc n)))) (sum 100000 0)`, "5000050000"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDocstrings(t *testing.T) {
+	tests := []evalData{
+		{`(define (sq x) "Returns x squared." (* x x)) (sq 3)`, "9"},
+		{`(define (sq x) "Returns x squared." (* x x)) (doc sq)`, `"(sq x)\nReturns x squared."`},
+		{`(define f (lambda (a . r) "Docs." r)) (doc 'f)`, `"(f a . r)\nDocs."`},
+		{`(doc (lambda r "Anonymous." r))`, `"(lambda . r)\nAnonymous."`},
+		{`(define (f) "Not a docstring") (list (f) (doc f))`, `("Not a docstring" #f)`},
+		{`(doc car)`, `"(car pair)\nReturns the first element of pair."`},
+		{`(doc 'if)`, `"(if test then [else])\nEvaluates then if test is true, otherwise else, which defaults to ()."`},
+		{`(doc 'unbound)`, "#f"},
+		{`(doc 1)`, "1:1: doc expects a procedure or symbol, got 1"},
+		{`(apropos "string->")`, "(string->number string->runes string->symbol)"},
+		{`(apropos "CODE POINT")`, "(char->integer integer->char runes->string string->runes string-rune)"},
+		{`(define (zzq) "Frobs the widget." 1) (apropos "widget")`, "(zzq)"},
+		{`(apropos "each name bound")`, "(let)"},
+		{`(apropos 'x)`, "1:1: apropos expects a string, got x"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	for _, eng := range engines {
+		in := New(&Options{MaxDepth: 100, Engine: eng})
+		_, err := in.EvalString(`(define (f n) (+ 1 (f n)))
+(define (g) (f 1))
+(g)`)
+		le, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("Expected *Error, got %v", err)
+		}
+		// The tree walker counts nested evaluations and the VM nested
+		// calls, so they stop at slightly different points.
+		if !strings.HasSuffix(strings.SplitN(le.Error(), "\n", 2)[0], ": Maximum evaluation depth 100 exceeded") {
+			t.Errorf("Unexpected error %s", le.Msg)
+		}
+		if len(le.Trace) != maxTrace || le.Trace[0] != "f 1:20" {
+			t.Errorf("Unexpected trace %v", le.Trace)
+		}
+		if n := len(le.Trace) + le.Elided; n < 99 || n > 100 {
+			t.Errorf("Expected about 100 calls in the trace, got %d", n)
+		}
+		// The interpreter is still usable after the error
+		if v, err := in.EvalString(`(f 'a)`); err == nil {
+			t.Errorf("Expected error, got %v", v)
+		}
+		if v, err := in.EvalString(`(+ 1 2)`); err != nil || v != 3 {
+			t.Errorf("Expected 3, got %v %v", v, err)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	for _, eng := range engines {
+		in := New(&Options{Engine: eng})
+		_, err := in.EvalString(`(define (f n) (if (= n 0) (/ 1 n) (+ 1 (f (- n 1)))))
+(define (g) (f 2))
+(g)`)
+		// g tail calls f, so g is no longer active when the error is raised
+		expected := `1:27: /: division by zero
+	in f 1:40
+	in f 1:40
+	in f 2:13`
+		if err == nil || err.Error() != expected {
+			t.Errorf("Expected\n%s\ngot\n%v", expected, err)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []evalData{
+		// Procedures may refer to globals that are not yet defined
+		{`(define (f x) (g x)) (display (quote never))`, "()"},
+		{`(define (f x) (g x)) (f 1)`, "1:16: Unbound variable g\n\tin f 1:22"},
+		{`(define (f) (set! z 1)) (f)`, "1:19: Unbound variable z\n\tin f 1:25"},
+		{`(define (f x) (g x)) (define (g x) x) (f 1)`, "1"},
+		{`(display 1) (if) (lambda) (let (x) x) (set! 1 2) (quote) (switch 1)`, `1:13: if expects 2 or 3 arguments, got 0
+1:18: lambda expects parameters and a body
+1:33: let expects bindings of the form (name value)
+1:39: set! expects a name and a value
+1:50: quote expects 1 argument, got 0
+1:58: switch expects a key list of at most one expression`},
+		{`(lambda (x 1) x)`, "1:12: Invalid parameter"},
+		{`(define (f) (let ((a 1)) (define b a) (lambda () (+ a b c)))) ((f))`, "1:57: Unbound variable c\n\tin lambda 1:63"},
+		{`(let ((a 1)) (+ a c))`, "1:19: Unbound variable c"},
+		{`(display x) (define x 1)`, "1:10: Unbound variable x"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+	// Nothing is evaluated when analysis fails
+	in := New(nil)
+	if _, err := in.EvalString(`(define y 1) (g)`); err == nil {
+		t.Errorf("Expected error")
+	}
+	if v, err := in.EvalString(`y`); err == nil {
+		t.Errorf("Expected y to be unbound, got %v", v)
+	}
+	// Mutually recursive procedures defined one at a time, as at the REPL
+	for _, eng := range engines {
+		in := New(&Options{Engine: eng})
+		for _, src := range []string{
+			`(define (ev? n) (if (= n 0) true (od? (- n 1))))`,
+			`(define (od? n) (if (= n 0) false (ev? (- n 1))))`,
+		} {
+			if _, err := in.EvalString(src); err != nil {
+				t.Errorf("For %s with engine %d: %v", src, eng, err)
+			}
+		}
+		if v, err := in.EvalString(`(ev? 10)`); v != true || err != nil {
+			t.Errorf("Expected #t with engine %d, got %v %v", eng, v, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []evalData{
+		{`(+ 1`, "1:5: Expecting ')' encountered EOF"},
+		{`)`, "1:1: Unexpected ')'"},
+		{`'`, "1:2: Unexpected EOF after quote"},
+		{`(equal? '(1 (2)) '(1 (2))) ; comment`, "#t"},
+		{"#!/usr/bin/env lisp\n(+ 1 #| 2 |# 3)", "4"},
+		{`(+ 1 #;(* 2 3) #; 4 5) #;6`, "6"},
+		{`(+ 1 #;)`, "1:8: Expecting datum after #;"},
+		{`(. a)`, "1:2: Unexpected '.'"},
+		{`'(a .)`, "1:6: Expecting datum after '.'"},
+		{`'(a . b c)`, "1:9: Expecting ')' after dotted tail"},
+		{`.`, "1:1: Unexpected '.'"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+var engines = []Engine{TreeWalker, BytecodeVM}
+
+// runEvalTest runs each test with every engine.
+func runEvalTest(td []evalData) error {
+	for _, eng := range engines {
+		for _, tst := range td {
+			in := New(&Options{Diagnostics: io.Discard, Stdout: io.Discard, Engine: eng})
+			v, err := in.EvalString(tst.test)
+			got := String(v)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tst.expected {
+				return fmt.Errorf("For test string %s with engine %d\nExpected:\t%s\nGot:\t\t%s\n", tst.test, eng, tst.expected, got)
+			}
+		}
+	}
+	return nil
+}
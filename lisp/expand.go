@@ -0,0 +1,59 @@
+package lisp
+
+import (
+	"io"
+	"strings"
+)
+
+// Expand reads the expressions of src and returns them as data with the
+// shorthand of the language written out in the core forms it stands for:
+// 'x as (quote x), and (define (f params ...) body ...) as
+// (define f (lambda (params ...) body ...)). There are no macros, so
+// nothing else is rewritten.
+func Expand(src string) ([]Value, error) {
+	p := newParser(newLexer(strings.NewReader(src), &Options{Diagnostics: io.Discard}))
+	var vs []Value
+	for {
+		e, err := p.next()
+		if err == io.EOF {
+			return vs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, expand(quote(e), map[*Pair]bool{}))
+	}
+}
+
+// expand rewrites the procedure definitions in v, which is not quoted.
+// seen holds the pairs met so far, so that labelled cycles end.
+func expand(v Value, seen map[*Pair]bool) Value {
+	p, ok := v.(*Pair)
+	if !ok || seen[p] {
+		return v
+	}
+	seen[p] = true
+	switch p.Car {
+	case Intern("quote"):
+		return p
+	case Intern("define"):
+		// (define (name . params) . body)
+		if rest, ok := p.Cdr.(*Pair); ok {
+			if sig, ok := rest.Car.(*Pair); ok {
+				lambda := &Pair{Intern("lambda"), &Pair{sig.Cdr, rest.Cdr}}
+				p = &Pair{p.Car, &Pair{sig.Car, &Pair{lambda, nil}}}
+				seen[p] = true
+			}
+		}
+	}
+	head := p
+	for {
+		p.Car = expand(p.Car, seen)
+		next, ok := p.Cdr.(*Pair)
+		if !ok || seen[next] {
+			return head
+		}
+		seen[next] = true
+		p = next
+	}
+}
@@ -0,0 +1,35 @@
+package lisp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		test     string
+		expected string
+	}{
+		{`'a`, `(quote a)`},
+		{`(f 'a "s")`, `(f (quote a) "s")`},
+		{`(define (f x . r) (g x) r)`, `(define f (lambda (x . r) (g x) r))`},
+		{`(define (f) (define (g) 1) (g))`, `(define f (lambda () (define g (lambda () 1)) (g)))`},
+		{`'(define (f) 1)`, `(quote (define (f) 1))`},
+		{`(define x 1) (let ((y 'b)) y)`, `(define x 1) (let ((y (quote b))) y)`},
+		{`(f`, `1:3: Expecting ')' encountered EOF`},
+	}
+	for _, tst := range tests {
+		vs, err := Expand(tst.test)
+		var got []string
+		for _, v := range vs {
+			got = append(got, String(v))
+		}
+		res := strings.Join(got, " ")
+		if err != nil {
+			res = err.Error()
+		}
+		if res != tst.expected {
+			t.Errorf("For test string %q\nExpected:\t%s\nGot:\t\t%s", tst.test, tst.expected, res)
+		}
+	}
+}
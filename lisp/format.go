@@ -0,0 +1,289 @@
+package lisp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatWidth is the line width Format fits lists within.
+const formatWidth = 80
+
+// An fnode is a piece of source as Format sees it: an atom, a comment or a
+// list, with its original text and rows.
+type fnode struct {
+	prefix   string // Quotes, datum comments, labels and dots before the datum
+	raw      string // The text of an atom or comment
+	comment  bool
+	trailing bool // A comment on the same line as the preceding token
+	blank    bool // Preceded by a blank line
+	atom     tokenTyp
+	// inner holds the comments between the prefix and the datum, each with
+	// the part of the prefix before it as its own prefix
+	inner    []*fnode
+	open     string // "(", "#(" or "#hash(" for a list
+	items    []*fnode
+	row, end int // The first and last rows of the node
+}
+
+// flat returns n on one line, or false if it must be broken across lines
+// because it contains a comment or was broken in the source. Lists of
+// atoms are never kept broken.
+func (n *fnode) flat() (string, bool) {
+	if n.comment || len(n.inner) > 0 || n.open == "" && n.row != n.end {
+		return "", false
+	}
+	if n.open == "" {
+		return n.prefix + n.raw, true
+	}
+	if n.row != n.end {
+		for _, it := range n.items {
+			if it.open != "" {
+				return "", false
+			}
+		}
+	}
+	var b strings.Builder
+	b.WriteString(n.prefix + n.open)
+	for i, it := range n.items {
+		s, ok := it.flat()
+		if !ok {
+			return "", false
+		}
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s)
+	}
+	b.WriteByte(')')
+	return b.String(), true
+}
+
+// endsLine reports whether n is a comment that runs to the end of its
+// line, rather than a block comment.
+func (n *fnode) endsLine() bool {
+	return n.comment && strings.HasPrefix(n.raw, ";")
+}
+
+// fparser builds fnodes from the tokens of a source.
+type fparser struct {
+	toks []*token
+	i    int
+	end  int // The last row of the previous token
+}
+
+func (p *fparser) next() *token {
+	t := p.toks[p.i]
+	p.i++
+	return t
+}
+
+// endRow returns the row t ends on.
+func endRow(t *token) int {
+	return t.row + strings.Count(t.raw, "\n")
+}
+
+// node parses the node starting with t.
+func (p *fparser) node(t *token) (*fnode, error) {
+	n := &fnode{row: t.row, blank: t.row > p.end+1 && p.end > 0}
+	trailing := t.row == p.end
+	// The prefixes read so far, for the message if the datum is missing
+	prefixes := ""
+	for {
+		switch t.typ {
+		case tokenQuote, tokenDatumComment, tokenLabel, tokenDot:
+			if t.typ == tokenDot {
+				n.prefix += ". "
+			} else {
+				n.prefix += t.raw
+			}
+			prefixes += t.raw
+			p.end = endRow(t)
+			t = p.next()
+			for t.typ == tokenComment {
+				// The reader skips a comment before the datum
+				n.inner = append(n.inner, &fnode{prefix: n.prefix, raw: t.raw, comment: true, row: t.row, end: endRow(t)})
+				n.prefix = ""
+				p.end = endRow(t)
+				t = p.next()
+			}
+			if t.typ == tokenEOF || t.typ == tokenRParen {
+				return nil, &parseError{t.row, t.col, fmt.Sprintf("Expecting datum after %s", prefixes), t.typ == tokenEOF}
+			}
+			continue
+		case tokenComment:
+			n.comment, n.trailing, n.raw = true, trailing, t.raw
+			// A comment runs to the end of its line, so a row count keeps
+			// it from being written flat
+			n.end = endRow(t) + 1
+			p.end = endRow(t)
+			return n, nil
+		case tokenError:
+			return nil, &parseError{t.row, t.col, t.err, false}
+		case tokenLParen, tokenVector, tokenHash:
+			n.open = t.raw
+			p.end = endRow(t)
+			for {
+				c := p.next()
+				if c.typ == tokenEOF {
+					return nil, &parseError{c.row, c.col, "Expecting ')' encountered EOF", true}
+				}
+				if c.typ == tokenRParen {
+					n.end = c.row
+					p.end = c.row
+					return n, nil
+				}
+				it, err := p.node(c)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, it)
+			}
+		case tokenRParen:
+			return nil, &parseError{t.row, t.col, "Unexpected ')'", false}
+		}
+		n.raw, n.atom, n.end = t.raw, t.typ, endRow(t)
+		p.end = n.end
+		return n, nil
+	}
+}
+
+// Format returns src laid out in the canonical style. Comments and the
+// text of atoms are kept as written, as are single blank lines between
+// expressions. A list written on one line stays on one line if it fits
+// within 80 columns. Other lists are broken with one element per line:
+// the bodies of special forms such as define and lambda are indented by
+// two columns, the arguments of calls are aligned under the first, and
+// the elements of data lists are aligned.
+func Format(src []byte) ([]byte, error) {
+	l := newLexer(bytes.NewReader(src), &Options{Diagnostics: io.Discard})
+	var toks []*token
+	for {
+		t := l.next()
+		toks = append(toks, t)
+		if t.typ == tokenEOF {
+			break
+		}
+	}
+	p := &fparser{toks: toks}
+	var top []*fnode
+	for {
+		t := p.next()
+		if t.typ == tokenEOF {
+			break
+		}
+		n, err := p.node(t)
+		if err != nil {
+			return nil, err
+		}
+		top = append(top, n)
+	}
+	f := &formatter{}
+	for i, n := range top {
+		switch {
+		case i == 0:
+		case n.trailing:
+			f.write(" ")
+		default:
+			f.write("\n")
+			if n.blank {
+				f.write("\n")
+			}
+		}
+		f.node(n)
+	}
+	if len(top) > 0 {
+		f.write("\n")
+	}
+	return []byte(f.b.String()), nil
+}
+
+type formatter struct {
+	b   strings.Builder
+	col int
+}
+
+func (f *formatter) write(s string) {
+	f.b.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		f.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		f.col += utf8.RuneCountInString(s)
+	}
+}
+
+// newline starts a new line indented to col, leaving an empty line first
+// if blank.
+func (f *formatter) newline(col int, blank bool) {
+	if blank {
+		f.write("\n")
+	}
+	f.write("\n" + strings.Repeat(" ", col))
+}
+
+func (f *formatter) node(n *fnode) {
+	if len(n.inner) > 0 {
+		col := f.col
+		for _, c := range n.inner {
+			f.write(c.prefix + c.raw)
+			if c.endsLine() {
+				f.newline(col, false)
+			} else {
+				f.write(" ")
+			}
+		}
+		datum := *n
+		datum.inner = nil
+		f.node(&datum)
+		return
+	}
+	if s, ok := n.flat(); ok && (n.open == "" || f.col+utf8.RuneCountInString(s) <= formatWidth) {
+		f.write(s)
+		return
+	}
+	if n.open == "" {
+		f.write(n.prefix + n.raw)
+		return
+	}
+	f.write(n.prefix + n.open)
+	open := f.col - 1
+	col := f.col
+	// The number of elements after the first kept on the first line, and
+	// the column the rest are aligned at
+	keep := 0
+	var head *fnode
+	if len(n.items) > 1 && !n.items[0].comment && n.items[0].atom == tokenAtom && n.items[0].prefix == "" && len(n.items[0].inner) == 0 {
+		head = n.items[0]
+	}
+	if head != nil {
+		if k, ok := bodyForms[head.raw]; ok {
+			keep, col = k, open+2
+		} else if col+utf8.RuneCountInString(head.raw)+1 > open+formatWidth/2 {
+			col = open + 2
+		} else {
+			keep, col = 1, col+utf8.RuneCountInString(head.raw)+1
+		}
+	}
+	// Whether the line so far ends in a comment, which must end the line
+	inComment := false
+	for i, it := range n.items {
+		switch {
+		case i == 0 && (!it.comment || it.trailing):
+		case it.comment && it.trailing:
+			f.write(" ")
+		case i <= keep && !inComment && !it.comment && !it.blank:
+			f.write(" ")
+		default:
+			keep = 0
+			f.newline(col, it.blank)
+		}
+		f.node(it)
+		inComment = it.endsLine()
+	}
+	if inComment {
+		f.newline(col, false)
+	}
+	f.write(")")
+}
@@ -0,0 +1,99 @@
+package lisp
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		test     string
+		expected string
+	}{
+		{"", ""},
+		{"(+   1\t2)", "(+ 1 2)\n"},
+		{"(define (f x)\n(if (= x 0) 1\n(* x (f (- x 1)))))", `(define (f x)
+  (if (= x 0)
+      1
+      (* x (f (- x 1)))))
+`},
+		{"(let ((a 1)\n(b 2))\n; inner\n(+ a b))", `(let ((a 1)
+      (b 2))
+  ; inner
+  (+ a b))
+`},
+		{"; header\n(f 1) ; call\n\n\n\n(g '(1\n 2 3))", `; header
+(f 1) ; call
+
+(g '(1 2 3))
+`},
+		{"(foo ; c\n)", "(foo ; c\n     )\n"},
+		{"#;(ignored)   (list #(1 2)  #hash((a . 1)))", "#;(ignored)\n(list #(1 2) #hash((a . 1)))\n"},
+		{"(a-very-long-function-name-that-goes-on-and-on argument-one\nargument-two argument-three)", `(a-very-long-function-name-that-goes-on-and-on
+  argument-one
+  argument-two
+  argument-three)
+`},
+		{"((1 2)\n(3 4) .\n5)", "((1 2)\n (3 4)\n . 5)\n"},
+		{"(display \"a\nb\" 'c)", "(display \"a\nb\"\n         'c)\n"},
+		{"#| block\ncomment |#\n(do\n(step))", "#| block\ncomment |#\n(do\n  (step))\n"},
+		// Comments between a prefix and its datum stay with it
+		{"'; c\nx", "'; c\nx\n"},
+		{"(a . ; c\nb)", "(a . ; c\n   b)\n"},
+		{"(f '#| c |# x)", "(f '#| c |# x)\n"},
+		{"(x #| c |#)", "(x #| c |#)\n"},
+	}
+	for _, tst := range tests {
+		out, err := Format([]byte(tst.test))
+		if err != nil {
+			t.Errorf("For test string %q: %v", tst.test, err)
+			continue
+		}
+		if string(out) != tst.expected {
+			t.Errorf("For test string %q\nExpected:\n%s\nGot:\n%s", tst.test, tst.expected, out)
+		}
+		// Formatting is idempotent, and keeps the meaning of the source
+		again, err := Format(out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("Formatting %q again gave %q, %v", out, again, err)
+		}
+		if data(t, tst.test) != data(t, string(out)) {
+			t.Errorf("For test string %q: formatting changed the data read", tst.test)
+		}
+	}
+}
+
+// data returns the data read from src, written one per line.
+func data(t *testing.T, src string) string {
+	p := newParser(newLexer(strings.NewReader(src), &Options{Diagnostics: io.Discard}))
+	var b strings.Builder
+	for {
+		e, err := p.next()
+		if err == io.EOF {
+			return b.String()
+		}
+		if err != nil {
+			t.Fatalf("Parsing %q: %v", src, err)
+		}
+		b.WriteString(String(quote(e)) + "\n")
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		test     string
+		expected string
+	}{
+		{"(a (b c)", "1:9: Expecting ')' encountered EOF"},
+		{"(a))", "1:4: Unexpected ')'"},
+		{"(a\n  '", "2:4: Expecting datum after '"},
+		{"(a \"b)", "1:4: Unterminated string"},
+	}
+	for _, tst := range tests {
+		_, err := Format([]byte(tst.test))
+		if err == nil || err.Error() != tst.expected {
+			t.Errorf("For test string %q\nExpected error:\t%s\nGot:\t\t%v", tst.test, tst.expected, err)
+		}
+	}
+}
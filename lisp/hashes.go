@@ -0,0 +1,228 @@
+package lisp
+
+import "fmt"
+
+// NewHash returns an empty hash table.
+func NewHash() *Hash {
+	return &Hash{index: map[Value]int{}}
+}
+
+// Get returns the value stored under k.
+func (h *Hash) Get(k Value) (Value, bool) {
+	i, ok := h.index[k]
+	if !ok {
+		return nil, false
+	}
+	return h.vals[i], true
+}
+
+// Set stores v under k.
+func (h *Hash) Set(k, v Value) {
+	if i, ok := h.index[k]; ok {
+		h.vals[i] = v
+		return
+	}
+	h.index[k] = len(h.keys)
+	h.keys = append(h.keys, k)
+	h.vals = append(h.vals, v)
+}
+
+// Delete removes k and its value.
+func (h *Hash) Delete(k Value) {
+	i, ok := h.index[k]
+	if !ok {
+		return
+	}
+	delete(h.index, k)
+	h.keys = append(h.keys[:i], h.keys[i+1:]...)
+	h.vals = append(h.vals[:i], h.vals[i+1:]...)
+	for j := i; j < len(h.keys); j++ {
+		h.index[h.keys[j]] = j
+	}
+}
+
+// Len returns the number of keys in h.
+func (h *Hash) Len() int {
+	return len(h.keys)
+}
+
+var hashBuiltins = []*Builtin{
+	{"make-hash", builtinMakeHash, 0, 1, "(make-hash [alist])\nReturns a hash table holding the pairs of alist."},
+	{"hash?", builtinIsHash, 1, 1, "(hash? x)\nReports whether x is a hash table."},
+	{"hash-ref", builtinHashRef, 2, 3, "(hash-ref h key [default])\nReturns the value of key in h, or default if it has none."},
+	{"hash-set!", builtinHashSet, 3, 3, "(hash-set! h key value)\nSets the value of key in h."},
+	{"hash-remove!", builtinHashRemove, 2, 2, "(hash-remove! h key)\nRemoves key from h."},
+	{"hash-has-key?", builtinHashHasKey, 2, 2, "(hash-has-key? h key)\nReports whether h has a value for key."},
+	{"hash-count", builtinHashCount, 1, 1, "(hash-count h)\nReturns the number of entries in h."},
+	{"hash-keys", builtinHashKeys, 1, 1, "(hash-keys h)\nReturns the keys of h in the order they were added."},
+	{"hash-values", builtinHashValues, 1, 1, "(hash-values h)\nReturns the values of h in the order their keys were added."},
+	{"hash->list", builtinHashToList, 1, 1, "(hash->list h)\nReturns the entries of h as a list of (key . value) pairs."},
+	{"hash-for-each", builtinHashForEach, 2, 2, "(hash-for-each h f)\nCalls (f key value) for each entry of h in order."},
+}
+
+func toHash(name string, v Value) (*Hash, error) {
+	h, ok := v.(*Hash)
+	if !ok {
+		return nil, fmt.Errorf("%s expects a hash table, got %s", name, String(v))
+	}
+	return h, nil
+}
+
+// (make-hash [alist]) returns a hash table holding the pairs of alist.
+func builtinMakeHash(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("make-hash", args, 0, 1); err != nil {
+		return nil, err
+	}
+	h := NewHash()
+	if len(args) == 0 {
+		return h, nil
+	}
+	l, err := toSlice("make-hash", args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, x := range l {
+		p, err := toPair("make-hash", x)
+		if err != nil {
+			return nil, err
+		}
+		h.Set(p.Car, p.Cdr)
+	}
+	return h, nil
+}
+
+func builtinIsHash(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(*Hash)
+	return ok, nil
+}
+
+// (hash-ref h key [default]) returns the value of key, or default if key
+// is missing.
+func builtinHashRef(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-ref", args, 2, 3); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-ref", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := h.Get(args[1]); ok {
+		return v, nil
+	}
+	if len(args) == 3 {
+		return args[2], nil
+	}
+	return nil, fmt.Errorf("hash-ref: no value for key %s", String(args[1]))
+}
+
+func builtinHashSet(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-set!", args, 3, 3); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-set!", args[0])
+	if err != nil {
+		return nil, err
+	}
+	h.Set(args[1], args[2])
+	return args[2], nil
+}
+
+func builtinHashRemove(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-remove!", args, 2, 2); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-remove!", args[0])
+	if err != nil {
+		return nil, err
+	}
+	h.Delete(args[1])
+	return nil, nil
+}
+
+func builtinHashHasKey(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-has-key?", args, 2, 2); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-has-key?", args[0])
+	if err != nil {
+		return nil, err
+	}
+	_, ok := h.Get(args[1])
+	return ok, nil
+}
+
+func builtinHashCount(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-count", args, 1, 1); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-count", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return h.Len(), nil
+}
+
+func builtinHashKeys(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-keys", args, 1, 1); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-keys", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return List(h.keys...), nil
+}
+
+func builtinHashValues(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-values", args, 1, 1); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-values", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return List(h.vals...), nil
+}
+
+// (hash->list h) returns the entries of h as a list of (key . value)
+// pairs.
+func builtinHashToList(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash->list", args, 1, 1); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash->list", args[0])
+	if err != nil {
+		return nil, err
+	}
+	vs := make([]Value, h.Len())
+	for i, k := range h.keys {
+		vs[i] = &Pair{k, h.vals[i]}
+	}
+	return List(vs...), nil
+}
+
+// (hash-for-each h f) calls (f key value) for each entry of h in order.
+// Entries added during the iteration are not visited.
+func builtinHashForEach(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("hash-for-each", args, 2, 2); err != nil {
+		return nil, err
+	}
+	h, err := toHash("hash-for-each", args[0])
+	if err != nil {
+		return nil, err
+	}
+	keys := append([]Value(nil), h.keys...)
+	for _, k := range keys {
+		v, ok := h.Get(k)
+		if !ok {
+			continue
+		}
+		if _, err := in.apply(args[1], []Value{k, v}); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,53 @@
+package lisp
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// parenColours is the number of colours parentheses cycle through by
+// depth.
+const parenColours = 6
+
+// HighlightStyle is a style sheet for the HTML Highlight writes.
+const HighlightStyle = `pre.lisp { background: #fafafa; color: #333; padding: 0.5em; }
+.lisp .comment { color: #8e908c; font-style: italic; }
+.lisp .keyword { color: #8959a8; font-weight: bold; }
+.lisp .builtin { color: #4271ae; }
+.lisp .string, .lisp .char { color: #718c00; }
+.lisp .number, .lisp .bool { color: #f5871f; }
+.lisp .punctuation { color: #3e999f; }
+.lisp .error { color: #c82829; text-decoration: underline wavy; }
+.lisp .paren-0 { color: #c82829; }
+.lisp .paren-1 { color: #f5871f; }
+.lisp .paren-2 { color: #b8a000; }
+.lisp .paren-3 { color: #718c00; }
+.lisp .paren-4 { color: #3e999f; }
+.lisp .paren-5 { color: #8959a8; }
+`
+
+// Highlight writes src to w as an HTML <pre class="lisp"> element, with
+// each token in a <span> whose class is the name of its TokenKind.
+// Parentheses also have the class paren-n, where n is their depth modulo
+// the six colours of HighlightStyle. White space and symbols are written
+// bare.
+func Highlight(w io.Writer, src []byte) error {
+	var b strings.Builder
+	b.WriteString(`<pre class="lisp">`)
+	for _, t := range Tokenize(src) {
+		text := html.EscapeString(string(src[t.Offset : t.Offset+t.Len]))
+		switch t.Kind {
+		case SpaceToken, SymbolToken:
+			b.WriteString(text)
+		case ParenToken:
+			fmt.Fprintf(&b, `<span class="paren paren-%d">%s</span>`, t.Depth%parenColours, text)
+		default:
+			fmt.Fprintf(&b, `<span class="%s">%s</span>`, t.Kind, text)
+		}
+	}
+	b.WriteString("</pre>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
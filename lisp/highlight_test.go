@@ -0,0 +1,32 @@
+package lisp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		test     string
+		expected string
+	}{
+		{"(define x \"<a&b>\") ; note", `<pre class="lisp"><span class="paren paren-0">(</span><span class="keyword">define</span> x <span class="string">&#34;&lt;a&amp;b&gt;&#34;</span><span class="paren paren-0">)</span> <span class="comment">; note</span></pre>` + "\n"},
+		{"(car 1)", `<pre class="lisp"><span class="paren paren-0">(</span><span class="builtin">car</span> <span class="number">1</span><span class="paren paren-0">)</span></pre>` + "\n"},
+		{"'#\\a #t)", `<pre class="lisp"><span class="punctuation">&#39;</span><span class="char">#\a</span> <span class="bool">#t</span><span class="error">)</span></pre>` + "\n"},
+	}
+	for _, tst := range tests {
+		var b strings.Builder
+		if err := Highlight(&b, []byte(tst.test)); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tst.expected {
+			t.Errorf("For test string %q\nExpected:\t%s\nGot:\t\t%s", tst.test, tst.expected, b.String())
+		}
+	}
+	// Depths cycle through the colours
+	var b strings.Builder
+	Highlight(&b, []byte("(((((((x)))))))"))
+	if !strings.Contains(b.String(), `paren-5">(</span><span class="paren paren-0">(`) {
+		t.Errorf("Parentheses deeper than the colours do not cycle: %s", b.String())
+	}
+}
@@ -0,0 +1,260 @@
+package lisp
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A Definition is a variable defined at the top level of a program.
+type Definition struct {
+	Name string
+	// Signature shows how a procedure is called, as in (f x y), and is
+	// empty for other variables
+	Signature string
+	Row, Col  int // The position of the name
+	// The extent of the define form, up to just after its ')'
+	StartRow, StartCol, EndRow, EndCol int
+}
+
+// Definitions returns the top level definitions of src in order. Source
+// that is being edited is read as far as it can be, with any lists left
+// open closed at the end.
+func Definitions(src []byte) []Definition {
+	var defs []Definition
+	var find func(es []*expr)
+	find = func(es []*expr) {
+		for _, e := range es {
+			if !e.isList() || e.first == nil || e.lit != 0 {
+				continue
+			}
+			args := e.items()[1:]
+			switch e.first.symbol() {
+			case "define":
+				if len(args) == 0 {
+					continue
+				}
+				d := Definition{StartRow: e.row, StartCol: e.col}
+				d.EndRow, d.EndCol = e.end()
+				name := args[0]
+				if name.isList() && name.first != nil {
+					params := name.rest
+					if params == nil {
+						params = name.tail
+					}
+					name = name.first
+					d.Signature = signature(name.symbol(), params)
+				} else if len(args) > 1 && isLambda(args[1]) {
+					d.Signature = signature(name.symbol(), args[1].items()[1])
+				}
+				if name.sym() == nil {
+					continue
+				}
+				d.Name = name.symbol()
+				d.Row, d.Col = name.pos()
+				defs = append(defs, d)
+			case "do":
+				find(args)
+			}
+		}
+	}
+	find(parseTolerant(src))
+	return defs
+}
+
+// isLambda reports whether e is a lambda expression with a body.
+func isLambda(e *expr) bool {
+	return e.isList() && e.first != nil && e.first.symbol() == "lambda" && len(e.items()) > 2
+}
+
+// DefinitionAt returns the position of the name that binds the variable
+// at row, col of src: a definition, parameter or let binding.
+func DefinitionAt(src []byte, row, col int) (defRow, defCol int, ok bool) {
+	es := parseTolerant(src)
+	e := atomAt(es, row, col)
+	if e == nil {
+		return 0, 0, false
+	}
+	b := resolve(es, nil).refs[e]
+	if b == nil {
+		return 0, 0, false
+	}
+	defRow, defCol = b.e.pos()
+	return defRow, defCol, true
+}
+
+// Hover returns a description of the symbol at row, col of src: how it is
+// called if it is a procedure defined in src, followed by its docstring,
+// or the documentation of a special form or builtin.
+func Hover(src []byte, row, col int) (string, bool) {
+	es := parseTolerant(src)
+	e := atomAt(es, row, col)
+	if e == nil || e.sym() == nil {
+		return "", false
+	}
+	if b := resolve(es, nil).refs[e]; b != nil {
+		if b.doc != "" {
+			return b.sig + "\n" + b.doc, true
+		}
+		return b.sig, b.sig != ""
+	}
+	return Doc(e.symbol())
+}
+
+// A CompletionKind says what sort of name a Completion is.
+type CompletionKind int
+
+const (
+	VariableCompletion CompletionKind = iota
+	ProcedureCompletion
+	SpecialFormCompletion
+)
+
+// A Completion is a name that may be completed.
+type Completion struct {
+	Name string
+	Kind CompletionKind
+	// Detail shows how a procedure is called
+	Detail string
+}
+
+// completionPoint is inserted where names are completed so that there is
+// an atom whose scope can be found.
+const completionPoint = "completion-point"
+
+// Completions returns the names visible at row, col of src that start
+// with the part of a symbol before it: the variables of the innermost
+// scopes first, then top level definitions, then the special forms and
+// builtins.
+func Completions(src []byte, row, col int) []Completion {
+	lines := strings.Split(string(src), "\n")
+	if row < 1 || row > len(lines) {
+		return nil
+	}
+	line := []rune(lines[row-1])
+	if col < 1 || col > len(line)+1 {
+		return nil
+	}
+	start := col - 1
+	for start > 0 && isAtomRune(line[start-1]) {
+		start--
+	}
+	prefix := string(line[start : col-1])
+	lines[row-1] = string(line[:col-1]) + completionPoint + string(line[col-1:])
+	es := parseTolerant([]byte(strings.Join(lines, "\n")))
+	point := atomAt(es, row, start+1)
+	if point == nil || point.atom.col != start+1 {
+		return nil
+	}
+	v := resolve(es, point)
+	seen := map[string]bool{}
+	var cs []Completion
+	add := func(c Completion) {
+		if !seen[c.Name] && strings.HasPrefix(c.Name, prefix) {
+			seen[c.Name] = true
+			cs = append(cs, c)
+		}
+	}
+	if v.visible == nil {
+		// The point is not an expression, so offer the top level names
+		var bs []*binding
+		for _, b := range v.scope.vars {
+			bs = append(bs, b)
+		}
+		v.visible = [][]*binding{bs}
+	}
+	for _, bs := range v.visible {
+		sort.Slice(bs, func(i, j int) bool { return bs[i].e.symbol() < bs[j].e.symbol() })
+		for _, b := range bs {
+			c := Completion{Name: b.e.symbol(), Detail: b.sig}
+			if b.sig != "" {
+				c.Kind = ProcedureCompletion
+			}
+			add(c)
+		}
+	}
+	for _, name := range docNames() {
+		d, _ := Doc(name)
+		c := Completion{Name: name, Kind: ProcedureCompletion, Detail: d[:strings.IndexByte(d, '\n')]}
+		if specialForm(name) {
+			c.Kind = SpecialFormCompletion
+		} else if _, ok := constants[name]; ok {
+			c.Kind, c.Detail = VariableCompletion, ""
+		}
+		add(c)
+	}
+	return cs
+}
+
+// isAtomRune reports whether r may appear in a symbol.
+func isAtomRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || isSymbolRune(r) || r == '_' || r == '.'
+}
+
+// parseTolerant parses as much of src as it can, closing any lists left
+// open at the end, for tools that work on source being edited.
+func parseTolerant(src []byte) []*expr {
+	opts := &Options{Diagnostics: io.Discard}
+	l := newLexer(bytes.NewReader(src), opts)
+	open := 0
+	for t := l.next(); t.typ != tokenEOF; t = l.next() {
+		switch t.typ {
+		case tokenLParen, tokenVector, tokenHash:
+			open++
+		case tokenRParen:
+			if open > 0 {
+				open--
+			}
+		}
+	}
+	if open > 0 {
+		src = append(append(src[:len(src):len(src)], '\n'), strings.Repeat(")", open)...)
+	}
+	p := newParser(newLexer(bytes.NewReader(src), opts))
+	var es []*expr
+	for {
+		e, err := p.next()
+		if err != nil {
+			return es
+		}
+		es = append(es, e)
+	}
+}
+
+// resolve finds the bindings of the names in es, and the variables visible
+// at point if it is not nil.
+func resolve(es []*expr, point *expr) *vetter {
+	v := newVetter()
+	v.refs, v.point = map[*expr]*binding{}, point
+	v.program(es)
+	return v
+}
+
+// atomAt returns the atom of es at row, col, or just before it.
+func atomAt(es []*expr, row, col int) *expr {
+	for _, e := range es {
+		if e == nil {
+			continue
+		}
+		if !e.isList() {
+			r, c := e.pos()
+			er, ec := e.end()
+			if r == row && er == row && c <= col && col <= ec {
+				return e
+			}
+			continue
+		}
+		if r, c := e.pos(); row < r || row == r && col < c {
+			continue
+		}
+		if r, c := e.end(); r != 0 && (row > r || row == r && col > c) {
+			continue
+		}
+		if a := atomAt([]*expr{e.first, e.rest, e.tail}, row, col); a != nil {
+			return a
+		}
+	}
+	return nil
+}
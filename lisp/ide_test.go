@@ -0,0 +1,126 @@
+package lisp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const ideSrc = `; A program
+(define (square x) (* x x))
+(define total 0)
+(define add (lambda (n . rest) (set! total (+ total n))))
+(define (sum-squares xs)
+  (let ((acc 0))
+    (fold (lambda (a x) (+ a (square x))) acc xs)))
+(do (define late 'x))
+(define (cube x) "Returns x cubed." (* x x x)) (cube 2)
+`
+
+func TestDefinitions(t *testing.T) {
+	var got []string
+	for _, d := range Definitions([]byte(ideSrc + "(define (open")) {
+		got = append(got, fmt.Sprintf("%s %q %d:%d %d:%d-%d:%d", d.Name, d.Signature, d.Row, d.Col, d.StartRow, d.StartCol, d.EndRow, d.EndCol))
+	}
+	expected := `square "(square x)" 2:10 2:1-2:28
+total "" 3:9 3:1-3:17
+add "(add n . rest)" 4:9 4:1-4:58
+sum-squares "(sum-squares xs)" 5:10 5:1-7:52
+late "" 8:13 8:5-8:21
+cube "(cube x)" 9:10 9:1-9:47
+open "(open)" 10:10 10:1-11:3`
+	if s := strings.Join(got, "\n"); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+}
+
+func TestDefinitionAt(t *testing.T) {
+	tests := []struct {
+		row, col       int
+		defRow, defCol int
+		ok             bool
+	}{
+		{7, 32, 2, 10, true}, // square
+		{7, 38, 7, 22, true}, // x, the lambda's parameter
+		{7, 44, 6, 10, true}, // acc
+		{7, 48, 5, 22, true}, // xs
+		{4, 48, 3, 9, true},  // total
+		{4, 41, 3, 9, true},  // total
+		{2, 10, 2, 10, true}, // square itself
+		{2, 21, 0, 0, false}, // *
+		{1, 3, 0, 0, false},  // In a comment
+	}
+	for _, tst := range tests {
+		r, c, ok := DefinitionAt([]byte(ideSrc), tst.row, tst.col)
+		if r != tst.defRow || c != tst.defCol || ok != tst.ok {
+			t.Errorf("At %d:%d expected %d:%d %v, got %d:%d %v", tst.row, tst.col, tst.defRow, tst.defCol, tst.ok, r, c, ok)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		row, col int
+		expected string
+	}{
+		{7, 32, "(square x)"},
+		{7, 7, builtinIndex["fold"].Doc},
+		{2, 21, builtinIndex["*"].Doc},
+		{6, 4, docs["let"]},
+		{7, 44, ""},
+		{9, 50, "(cube x)\nReturns x cubed."},
+	}
+	for _, tst := range tests {
+		if got, _ := Hover([]byte(ideSrc), tst.row, tst.col); got != tst.expected {
+			t.Errorf("At %d:%d expected %q, got %q", tst.row, tst.col, tst.expected, got)
+		}
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	tests := []struct {
+		src      string
+		row, col int
+		expected string // The first completions
+	}{
+		{"(define (f xs) (let ((x 1)) (+ x", 1, 33, "x xs"},
+		{"(define (f xs) (let ((x 1)) (f", 1, 31, "f false filter fold"},
+		{"(define (f xs) (let ((x 1)) (", 1, 30, "x xs f * + -"},
+		{"(define (f xs) xs)\n(s", 2, 3, "set! set-car! set-cdr! sort string->number"},
+		{"(define (f xs) xs)\n; (f", 2, 5, ""},
+		{"(define (f xs) xs)\n\"(f", 2, 4, ""},
+	}
+	for _, tst := range tests {
+		var got []string
+		for _, c := range Completions([]byte(tst.src), tst.row, tst.col) {
+			got = append(got, c.Name)
+		}
+		s := strings.Join(got, " ")
+		if tst.expected == "" && s != "" || !strings.HasPrefix(s, tst.expected) {
+			t.Errorf("For %q at %d:%d expected %s..., got %s", tst.src, tst.row, tst.col, tst.expected, s)
+		}
+	}
+}
+
+func TestDocs(t *testing.T) {
+	// Every name an interpreter starts with: the constants, builtins and
+	// procedures of the prelude
+	names := map[string]bool{}
+	for _, name := range New(nil).Names() {
+		names[name] = true
+	}
+	for name := range names {
+		if d, _ := Doc(name); d == "" {
+			t.Errorf("%s is not documented", name)
+		}
+	}
+	for _, name := range docNames() {
+		d, _ := Doc(name)
+		if !names[name] && !specialForm(name) {
+			t.Errorf("%s is documented but is not a builtin", name)
+		}
+		if !strings.Contains(d, "\n") {
+			t.Errorf("The documentation of %s does not show how it is called", name)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package lisp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Interpreter evaluates Lisp programs in a global environment that persists
+// between calls to Eval.
+type Interpreter struct {
+	opts     *Options
+	global   map[*Symbol]Value
+	stdout   io.Writer
+	stderr   io.Writer
+	stdin    *bufio.Reader
+	depth    int
+	maxDepth int
+	engine   Engine
+
+	base    map[*Symbol]Value  // The constants and builtins, which modules start with
+	dir     string             // The directory of the file being evaluated
+	loading []string           // The files being loaded, outermost first
+	modules map[string]*module // The modules imported, by path
+}
+
+// builtinTables are the builtins every interpreter starts with.
+var builtinTables = [][]*Builtin{builtins, listBuiltins, stringBuiltins, charBuiltins, vectorBuiltins, hashBuiltins}
+
+// New returns an interpreter with the builtins defined and the prelude
+// evaluated. opts may be nil. New panics if a prelude given by
+// Options.Prelude fails; Start returns the error instead.
+func New(opts *Options) *Interpreter {
+	in, err := Start(opts)
+	if err != nil {
+		panic(fmt.Sprintf("lisp: %v", err))
+	}
+	return in
+}
+
+// Start returns an interpreter as New does, or the error raised
+// evaluating the prelude.
+func Start(opts *Options) (*Interpreter, error) {
+	in := &Interpreter{
+		opts:     opts,
+		global:   newEnv(),
+		base:     newEnv(),
+		stdout:   opts.stdout(),
+		stderr:   opts.stderr(),
+		stdin:    bufio.NewReader(opts.stdin()),
+		maxDepth: opts.maxDepth(),
+		engine:   opts.engine(),
+		modules:  map[string]*module{},
+	}
+	if src := opts.prelude(); src != "" {
+		if err := in.evalPrelude(src); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// newEnv returns a global environment holding the constants and builtins.
+func newEnv() map[*Symbol]Value {
+	env := map[*Symbol]Value{}
+	for name, v := range constants {
+		env[Intern(name)] = v
+	}
+	for _, bs := range builtinTables {
+		for _, b := range bs {
+			env[Intern(b.Name)] = b
+		}
+	}
+	return env
+}
+
+// Define binds name to v in the global environment.
+func (in *Interpreter) Define(name string, v Value) {
+	in.global[Intern(name)] = v
+}
+
+// Lookup returns the value of the global variable name.
+func (in *Interpreter) Lookup(name string) (Value, bool) {
+	v, ok := in.global[Intern(name)]
+	return v, ok
+}
+
+// Names returns the names bound in the global environment, sorted.
+func (in *Interpreter) Names() []string {
+	names := make([]string, 0, len(in.global))
+	for sym := range in.global {
+		names = append(names, sym.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval reads every expression from r and then evaluates them in order,
+// returning the value of the last. An input that ends inside an expression
+// returns an error matching io.ErrUnexpectedEOF.
+func (in *Interpreter) Eval(r io.Reader) (Value, error) {
+	rs, ok := r.(io.RuneScanner)
+	if !ok {
+		rs = bufio.NewReader(r)
+	}
+	p := newParser(newLexer(rs, in.opts))
+	var es []*expr
+	for {
+		e, err := p.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		es = append(es, e)
+	}
+	ns, err := in.analyze(es)
+	if err != nil {
+		return nil, err
+	}
+	if in.engine == BytecodeVM {
+		return in.runBody(ns)
+	}
+	return in.evalBody(ns)
+}
+
+// runBody compiles each of ns and then runs them in turn.
+func (in *Interpreter) runBody(ns []node) (Value, error) {
+	var ps []*proto
+	for _, n := range ns {
+		p, err := compileTop(n)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	var v Value
+	for _, p := range ps {
+		var err error
+		if v, err = in.run(p); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// EvalString evaluates the expressions in src.
+func (in *Interpreter) EvalString(src string) (Value, error) {
+	return in.Eval(strings.NewReader(src))
+}
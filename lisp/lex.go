@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type lexer struct {
@@ -19,6 +20,14 @@ type lexer struct {
 	col        int
 	tcol       int
 	trow       int
+	// off is the byte offset after the last rune read or peeked, which
+	// was size bytes long, and toff the offset of the current token
+	off, size int
+	toff      int
+	// nlcol is the column of the last newline read, which ended row-1
+	nlcol int
+	// spaces makes next return runs of white space as tokenSpace tokens
+	spaces bool
 }
 
 var EOFRUNE = rune(-1)
@@ -36,6 +45,16 @@ const (
 	tokenQuote
 	tokenAtom
 	tokenNumber
+	tokenDatumComment
+	tokenDot
+	tokenString
+	tokenChar
+	tokenVector // #(
+	tokenHash   // #hash(
+	tokenBool
+	tokenLabel    // #n=
+	tokenLabelRef // #n#
+	tokenSpace    // Only returned by lexers reading spaces
 )
 
 type token struct {
@@ -45,10 +64,12 @@ type token struct {
 	row int
 	col int
 	err string
+	// The byte offsets of the start of the token and just after it
+	off, end int
 }
 
-func newLexer(rr io.RuneScanner) *lexer {
-	l := log.New(os.Stderr, "", 0)
+func newLexer(rr io.RuneScanner, opts *Options) *lexer {
+	l := log.New(opts.diagnostics(), "", 0)
 	lex := &lexer{rr: rr, log: l, row: 1}
 	return lex
 }
@@ -58,6 +79,7 @@ func (l *lexer) next() *token {
 		r := l.peek()
 		l.tcol = l.col
 		l.trow = l.row
+		l.toff = l.off - l.size
 		switch {
 		case r == EOFRUNE:
 			_ = l.read()
@@ -66,8 +88,16 @@ func (l *lexer) next() *token {
 			_ = l.read()
 			return l.makeToken(tokenError, nil, "", "Rune Error")
 		case r == ';':
-			return l.readComment()
+			return l.readComment("")
+		case r == '#':
+			return l.readHash()
 		case r == '\n', unicode.IsSpace(r):
+			if l.spaces {
+				if r == '\n' {
+					l.trow, l.tcol = l.row-1, l.nlcol
+				}
+				return l.readSpace()
+			}
 			_ = l.read()
 			continue
 		case r == '(':
@@ -79,7 +109,9 @@ func (l *lexer) next() *token {
 		case r == '\'':
 			_ = l.read()
 			return l.makeToken(tokenQuote, nil, "'", "")
-		case unicode.IsLetter(r):
+		case r == '"':
+			return l.readString()
+		case unicode.IsLetter(r), r != '-' && isSymbolRune(r):
 			return l.readAtom()
 		case unicode.IsNumber(r), r == '.', r == '-':
 			return l.readNumber()
@@ -90,9 +122,19 @@ func (l *lexer) next() *token {
 	}
 }
 
+// readSpace reads a run of white space.
+func (l *lexer) readSpace() *token {
+	var b bytes.Buffer
+	for r := l.peek(); r == '\n' || unicode.IsSpace(r); r = l.peek() {
+		b.WriteRune(l.read())
+	}
+	return l.makeToken(tokenSpace, nil, b.String(), "")
+}
+
 // Comments ;.*\n
-func (l *lexer) readComment() *token {
+func (l *lexer) readComment(prefix string) *token {
 	var b bytes.Buffer
+	b.WriteString(prefix)
 	for {
 		r := l.peek()
 		switch r {
@@ -107,31 +149,208 @@ func (l *lexer) readComment() *token {
 	}
 }
 
-// Atoms [A-Za-z][A-Za-z0-9-_]*[A-Za-z0-9]
-func (l *lexer) readAtom() *token {
+// readHash reads the syntax introduced by '#': a #! line at the very start
+// of the input, a #| |# block comment, the #; prefix commenting out the
+// next datum, a #\ character, the booleans #t and #f, the opening #( of a
+// vector or #hash( of a hash table, or a #n= datum label or #n# reference
+// to one.
+func (l *lexer) readHash() *token {
+	_ = l.read()
+	r := l.peek()
+	switch {
+	case r == '!' && l.trow == 1 && l.tcol == 1:
+		return l.readComment("#")
+	case r == '|':
+		return l.readBlockComment()
+	case r == ';':
+		_ = l.read()
+		return l.makeToken(tokenDatumComment, nil, "#;", "")
+	case r == '\\':
+		return l.readChar()
+	case r == '(':
+		_ = l.read()
+		return l.makeToken(tokenVector, nil, "#(", "")
+	case r >= '0' && r <= '9':
+		var b bytes.Buffer
+		for r = l.peek(); r >= '0' && r <= '9'; r = l.peek() {
+			b.WriteRune(l.read())
+		}
+		n, err := strconv.Atoi(b.String())
+		if (r == '=' || r == '#') && err == nil {
+			_ = l.read()
+			if r == '=' {
+				return l.makeToken(tokenLabel, n, "#"+b.String()+"=", "")
+			}
+			return l.makeToken(tokenLabelRef, n, "#"+b.String()+"#", "")
+		}
+		return l.makeToken(tokenError, nil, "#"+b.String(), fmt.Sprintf("Invalid datum label [#%s]", b.String()))
+	case unicode.IsLetter(r):
+		var b bytes.Buffer
+		b.WriteRune('#')
+		for !isDelimiter(l.peek()) && l.peek() != ERRRUNE {
+			b.WriteRune(l.read())
+		}
+		switch w := b.String(); {
+		case w == "#t", w == "#true":
+			return l.makeToken(tokenBool, true, w, "")
+		case w == "#f", w == "#false":
+			return l.makeToken(tokenBool, false, w, "")
+		case w == "#hash" && l.peek() == '(':
+			b.WriteRune(l.read())
+			return l.makeToken(tokenHash, nil, b.String(), "")
+		}
+		return l.makeToken(tokenError, nil, b.String(), fmt.Sprintf("Unexpected token[%s]", b.String()))
+	}
+	_ = l.read()
+	return l.makeToken(tokenError, nil, "#"+string(r), fmt.Sprintf("Unexpected token[#%s]", string(r)))
+}
+
+// Block comments #| ... |#, which may nest
+func (l *lexer) readBlockComment() *token {
 	var b bytes.Buffer
+	b.WriteRune('#')
+	b.WriteRune(l.read())
+	for depth := 1; depth > 0; {
+		r := l.read()
+		switch r {
+		case EOFRUNE:
+			return l.makeToken(tokenError, nil, b.String(), "Unterminated block comment")
+		case ERRRUNE:
+			return l.makeToken(tokenError, nil, "", "Rune Error")
+		}
+		b.WriteRune(r)
+		if r == '|' && l.peek() == '#' {
+			b.WriteRune(l.read())
+			depth--
+		} else if r == '#' && l.peek() == '|' {
+			b.WriteRune(l.read())
+			depth++
+		}
+	}
+	return l.makeToken(tokenComment, nil, b.String(), "")
+}
+
+// charNames are the characters that may be written #\name.
+var charNames = map[string]rune{
+	"nul":       0,
+	"alarm":     '\a',
+	"backspace": '\b',
+	"tab":       '\t',
+	"newline":   '\n',
+	"return":    '\r',
+	"escape":    0x1b,
+	"space":     ' ',
+	"delete":    0x7f,
+}
+
+// Characters #\c for any rune c, #\name, or #\xHH... giving a code point in
+// hex. The '#' has already been read.
+func (l *lexer) readChar() *token {
+	_ = l.read()
+	var b bytes.Buffer
+	b.WriteString("#\\")
 	r := l.read()
+	if r == EOFRUNE || r == ERRRUNE {
+		return l.makeToken(tokenError, nil, b.String(), "Expecting character after #\\")
+	}
 	b.WriteRune(r)
+	for !isDelimiter(l.peek()) && l.peek() != ERRRUNE {
+		b.WriteRune(l.read())
+	}
+	raw := b.String()
+	name := raw[2:]
+	if utf8.RuneCountInString(name) == 1 {
+		return l.makeToken(tokenChar, Char(r), raw, "")
+	}
+	if c, ok := charNames[name]; ok {
+		return l.makeToken(tokenChar, Char(c), raw, "")
+	}
+	if name[0] == 'x' {
+		if n, err := strconv.ParseUint(name[1:], 16, 32); err == nil && utf8.ValidRune(rune(n)) {
+			return l.makeToken(tokenChar, Char(n), raw, "")
+		}
+	}
+	return l.makeToken(tokenError, nil, raw, fmt.Sprintf("Unknown character %s", raw))
+}
+
+// Strings "..." with the escapes of Go's interpreted string literals. A
+// string may span lines.
+func (l *lexer) readString() *token {
+	var b bytes.Buffer
+	b.WriteRune(l.read())
+	for {
+		r := l.read()
+		switch r {
+		case EOFRUNE:
+			return l.makeToken(tokenError, nil, b.String(), "Unterminated string")
+		case ERRRUNE:
+			return l.makeToken(tokenError, nil, "", "Rune Error")
+		}
+		b.WriteRune(r)
+		if r == '"' {
+			break
+		}
+		if r == '\\' && l.peek() != EOFRUNE && l.peek() != ERRRUNE {
+			b.WriteRune(l.read())
+		}
+	}
+	raw := b.String()
+	var v strings.Builder
+	for s := raw[1 : len(raw)-1]; s != ""; {
+		r, _, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			return l.makeToken(tokenError, nil, raw, fmt.Sprintf("Invalid escape in string %s", raw))
+		}
+		v.WriteRune(r)
+		s = tail
+	}
+	return l.makeToken(tokenString, v.String(), raw, "")
+}
+
+// Atoms ([A-Za-z]|[*+/<>=!?%&:])[A-Za-z0-9-_*+/<>=!?%&:.]* not ending in - or _
+// A lone - is also an atom.
+func (l *lexer) readAtom() *token {
+	var b bytes.Buffer
+	b.WriteRune(l.read())
+	return l.readAtomRest(&b)
+}
+
+func (l *lexer) readAtomRest(b *bytes.Buffer) *token {
 	for {
 		r := l.peek()
 		switch {
-		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+		case unicode.IsLetter(r), unicode.IsDigit(r), isSymbolRune(r), r == '_', r == '.':
 			_ = l.read()
 			b.WriteRune(r)
 		default:
-			if unicode.IsLetter(l.last) || unicode.IsNumber(l.last) {
-				return l.makeToken(tokenAtom, b.String(), b.String(), "")
+			s := b.String()
+			if s == "-" || !strings.HasSuffix(s, "-") && !strings.HasSuffix(s, "_") {
+				return l.makeToken(tokenAtom, Intern(s), s, "")
 			}
-			return l.makeToken(tokenError, nil, b.String(), fmt.Sprintf("Invalid Atom[%s]", b.String()))
+			return l.makeToken(tokenError, nil, s, fmt.Sprintf("Invalid Atom[%s]", s))
 		}
 	}
 }
 
+// isSymbolRune reports whether r may appear in an atom besides letters,
+// digits, '_' and '.'.
+func isSymbolRune(r rune) bool {
+	return strings.ContainsRune("*+-/<>=!?%&:", r)
+}
+
 func (l *lexer) readNumber() *token {
 	var b bytes.Buffer
 	r := l.read()
 	dec := r == '.'
 	b.WriteRune(r)
+	if dec && isDelimiter(l.peek()) {
+		return l.makeToken(tokenDot, nil, ".", "")
+	}
+	if r == '-' {
+		if n := l.peek(); !unicode.IsNumber(n) && n != '.' {
+			return l.readAtomRest(&b)
+		}
+	}
 	for {
 		r := l.peek()
 		switch {
@@ -140,7 +359,7 @@ func (l *lexer) readNumber() *token {
 			_ = l.read()
 			b.WriteRune(r)
 		default:
-			if r == '\n' || r == '(' || r == ')' || r == ' ' {
+			if isDelimiter(r) {
 				var (
 					n   interface{}
 					err error
@@ -155,19 +374,30 @@ func (l *lexer) readNumber() *token {
 				}
 				return l.makeToken(tokenNumber, n, b.String(), "")
 			}
+			for !isDelimiter(r) && r != ERRRUNE {
+				b.WriteRune(l.read())
+				r = l.peek()
+			}
 			return l.makeToken(tokenError, nil, b.String(), fmt.Sprintf("Invalid Number [%s]", b.String()))
 		}
 	}
 }
 
+// isDelimiter reports whether r ends a number or atom.
+func isDelimiter(r rune) bool {
+	return r == EOFRUNE || r == '(' || r == ')' || r == ';' || r == '"' || unicode.IsSpace(r)
+}
+
 func (l *lexer) read() rune {
 	l.last = l.curr
 	if l.peeking {
 		l.peeking = false
 		return l.curr
 	}
-	r, _, err := l.rr.ReadRune()
+	r, size, err := l.rr.ReadRune()
+	l.off, l.size = l.off+size, size
 	if r == '\n' {
+		l.nlcol = l.col + 1
 		l.row = l.row + 1
 		l.col = 0
 	} else {
@@ -187,7 +417,10 @@ func (l *lexer) read() rune {
 }
 
 func (l *lexer) makeToken(t tokenTyp, v interface{}, r, e string) *token {
-	tok := &token{typ: t, val: v, raw: r, row: l.trow, col: l.tcol, err: e}
+	tok := &token{typ: t, val: v, raw: r, row: l.trow, col: l.tcol, err: e, off: l.toff, end: l.off}
+	if l.peeking {
+		tok.end -= l.size
+	}
 	return tok
 }
 
@@ -196,8 +429,10 @@ func (l *lexer) peek() rune {
 		return l.curr
 	}
 	l.peeking = true
-	r, _, err := l.rr.ReadRune()
+	r, size, err := l.rr.ReadRune()
+	l.off, l.size = l.off+size, size
 	if r == '\n' {
+		l.nlcol = l.col + 1
 		l.row = l.row + 1
 		l.col = 0
 	} else {
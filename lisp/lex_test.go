@@ -1,7 +1,10 @@
 package lisp
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 )
@@ -29,6 +32,44 @@ func TestComment(t *testing.T) {
 	}
 }
 
+func TestHashComment(t *testing.T) {
+	tests := []testData{
+		{`#!/usr/bin/env lisp
+(a #| block
+#| nested |# |# b)`, []*token{
+			&token{typ: tokenComment, raw: "#!/usr/bin/env lisp", row: 1, col: 1},
+			&token{typ: tokenLParen, row: 2, col: 1},
+			&token{typ: tokenAtom, val: "a", raw: "a", row: 2, col: 2},
+			&token{typ: tokenComment, raw: "#| block\n#| nested |# |#", row: 2, col: 4},
+			&token{typ: tokenAtom, val: "b", raw: "b", row: 3, col: 17},
+			&token{typ: tokenRParen, row: 3, col: 18},
+			&token{typ: tokenEOF, row: 3, col: 19}},
+		},
+		{`(a #;(b c) d)`, []*token{
+			&token{typ: tokenLParen, row: 1, col: 1},
+			&token{typ: tokenAtom, val: "a", raw: "a", row: 1, col: 2},
+			&token{typ: tokenDatumComment, row: 1, col: 4},
+			&token{typ: tokenLParen, row: 1, col: 6},
+			&token{typ: tokenAtom, val: "b", raw: "b", row: 1, col: 7},
+			&token{typ: tokenAtom, val: "c", raw: "c", row: 1, col: 9},
+			&token{typ: tokenRParen, row: 1, col: 10},
+			&token{typ: tokenAtom, val: "d", raw: "d", row: 1, col: 12},
+			&token{typ: tokenRParen, row: 1, col: 13},
+			&token{typ: tokenEOF, row: 1, col: 14}},
+		},
+		{` #!/usr/bin/env lisp`, []*token{
+			&token{typ: tokenError, raw: "#!", row: 1, col: 2}},
+		},
+		{`#| open`, []*token{
+			&token{typ: tokenError, raw: "#| open", row: 1, col: 1}},
+		},
+	}
+
+	if err := runTokenTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestRowCol(t *testing.T) {
 	tests := []testData{
 		{`; This is a comment
@@ -78,6 +119,13 @@ func TestParen(t *testing.T) {
 			&token{typ: tokenRParen, row: 2, col: 8},
 			&token{typ: tokenEOF, row: 2, col: 9}},
 		},
+		{`#(#hash())`, []*token{
+			&token{typ: tokenVector, row: 1, col: 1},
+			&token{typ: tokenHash, row: 1, col: 3},
+			&token{typ: tokenRParen, row: 1, col: 9},
+			&token{typ: tokenRParen, row: 1, col: 10},
+			&token{typ: tokenEOF, row: 1, col: 11}},
+		},
 	}
 	if err := runTokenTest(tests); err != nil {
 		t.Error(err)
@@ -121,6 +169,16 @@ func TestAtom(t *testing.T) {
 			&token{typ: tokenAtom, val: "test", raw: "test", row: 1, col: 1},
 			&token{typ: tokenEOF, row: 1, col: 5}},
 		},
+		{`(<= *args* - set! -x)`, []*token{
+			&token{typ: tokenLParen, row: 1, col: 1},
+			&token{typ: tokenAtom, val: "<=", raw: "<=", row: 1, col: 2},
+			&token{typ: tokenAtom, val: "*args*", raw: "*args*", row: 1, col: 5},
+			&token{typ: tokenAtom, val: "-", raw: "-", row: 1, col: 12},
+			&token{typ: tokenAtom, val: "set!", raw: "set!", row: 1, col: 14},
+			&token{typ: tokenAtom, val: "-x", raw: "-x", row: 1, col: 19},
+			&token{typ: tokenRParen, row: 1, col: 21},
+			&token{typ: tokenEOF, row: 1, col: 22}},
+		},
 	}
 
 	if err := runTokenTest(tests); err != nil {
@@ -149,7 +207,12 @@ func TestNumber(t *testing.T) {
 		{`(-
 		123())`, []*token{
 			&token{typ: tokenLParen, row: 1, col: 1},
-			&token{typ: tokenError, raw: "-", row: 1, col: 2}},
+			&token{typ: tokenAtom, val: "-", raw: "-", row: 1, col: 2},
+			&token{typ: tokenNumber, val: 123, row: 2, col: 3},
+			&token{typ: tokenLParen, row: 2, col: 6},
+			&token{typ: tokenRParen, row: 2, col: 7},
+			&token{typ: tokenRParen, row: 2, col: 8},
+			&token{typ: tokenEOF, row: 2, col: 9}},
 		},
 		{`(123(4s4
 	))`, []*token{
@@ -196,10 +259,122 @@ func TestFloat(t *testing.T) {
 	}
 }
 
+type errRuneScanner struct{}
+
+func (errRuneScanner) ReadRune() (rune, int, error) { return 0, 0, errors.New("broken") }
+func (errRuneScanner) UnreadRune() error            { return nil }
+
+func TestDot(t *testing.T) {
+	tests := []testData{
+		{`(a . .5)`, []*token{
+			&token{typ: tokenLParen, row: 1, col: 1},
+			&token{typ: tokenAtom, val: "a", raw: "a", row: 1, col: 2},
+			&token{typ: tokenDot, row: 1, col: 4},
+			&token{typ: tokenNumber, val: .5, row: 1, col: 6},
+			&token{typ: tokenRParen, row: 1, col: 8},
+			&token{typ: tokenEOF, row: 1, col: 9}},
+		},
+		{`(a .)`, []*token{
+			&token{typ: tokenLParen, row: 1, col: 1},
+			&token{typ: tokenAtom, val: "a", raw: "a", row: 1, col: 2},
+			&token{typ: tokenDot, row: 1, col: 4},
+			&token{typ: tokenRParen, row: 1, col: 5},
+			&token{typ: tokenEOF, row: 1, col: 6}},
+		},
+	}
+
+	if err := runTokenTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []testData{
+		{`("a b" "\"q\"\n\u00e9" "two
+lines")`, []*token{
+			&token{typ: tokenLParen, row: 1, col: 1},
+			&token{typ: tokenString, val: "a b", raw: `"a b"`, row: 1, col: 2},
+			&token{typ: tokenString, val: "\"q\"\né", raw: `"\"q\"\n\u00e9"`, row: 1, col: 8},
+			&token{typ: tokenString, val: "two\nlines", raw: "\"two\nlines\"", row: 1, col: 24},
+			&token{typ: tokenRParen, row: 2, col: 7},
+			&token{typ: tokenEOF, row: 2, col: 8}},
+		},
+		{`"bad \q"`, []*token{
+			&token{typ: tokenError, raw: `"bad \q"`, row: 1, col: 1}},
+		},
+		{`"open`, []*token{
+			&token{typ: tokenError, raw: `"open`, row: 1, col: 1}},
+		},
+		// A string ends the token before it
+		{`a"b"12"c"#t"d"#\e"f"`, []*token{
+			&token{typ: tokenAtom, val: Intern("a"), raw: "a", row: 1, col: 1},
+			&token{typ: tokenString, val: "b", raw: `"b"`, row: 1, col: 2},
+			&token{typ: tokenNumber, val: 12, raw: "12", row: 1, col: 5},
+			&token{typ: tokenString, val: "c", raw: `"c"`, row: 1, col: 7},
+			&token{typ: tokenBool, val: true, raw: "#t", row: 1, col: 10},
+			&token{typ: tokenString, val: "d", raw: `"d"`, row: 1, col: 12},
+			&token{typ: tokenChar, val: Char('e'), raw: `#\e`, row: 1, col: 15},
+			&token{typ: tokenString, val: "f", raw: `"f"`, row: 1, col: 18},
+			&token{typ: tokenEOF, row: 1, col: 21}},
+		},
+	}
+
+	if err := runTokenTest(tests); err != nil {
+		t.Error(err)
+	}
+	l := newLexer(strings.NewReader(`"\"q\"\n\u00e9"`), nil)
+	if tk := l.next(); tk.val != "\"q\"\né" {
+		t.Errorf("Expected unescaped string, got %q", tk.val)
+	}
+}
+
+func TestChar(t *testing.T) {
+	tests := []testData{
+		{`(#\a #\space #\x41 #\( #\é)`, []*token{
+			&token{typ: tokenLParen, row: 1, col: 1},
+			&token{typ: tokenChar, val: Char('a'), raw: `#\a`, row: 1, col: 2},
+			&token{typ: tokenChar, val: Char(' '), raw: `#\space`, row: 1, col: 6},
+			&token{typ: tokenChar, val: Char('A'), raw: `#\x41`, row: 1, col: 14},
+			&token{typ: tokenChar, val: Char('('), raw: `#\(`, row: 1, col: 20},
+			&token{typ: tokenChar, val: Char('é'), raw: `#\é`, row: 1, col: 24},
+			&token{typ: tokenRParen, row: 1, col: 27},
+			&token{typ: tokenEOF, row: 1, col: 28}},
+		},
+		{`(#t #false)`, []*token{
+			&token{typ: tokenLParen, row: 1, col: 1},
+			&token{typ: tokenBool, val: true, raw: "#t", row: 1, col: 2},
+			&token{typ: tokenBool, val: false, raw: "#false", row: 1, col: 5},
+			&token{typ: tokenRParen, row: 1, col: 11},
+			&token{typ: tokenEOF, row: 1, col: 12}},
+		},
+		{`#\bogus`, []*token{
+			&token{typ: tokenError, raw: `#\bogus`, row: 1, col: 1}},
+		},
+		{`#\`, []*token{
+			&token{typ: tokenError, raw: `#\`, row: 1, col: 1}},
+		},
+	}
+
+	if err := runTokenTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	var b bytes.Buffer
+	lxr := newLexer(errRuneScanner{}, &Options{Diagnostics: &b})
+	if tok := lxr.next(); tok.typ != tokenError {
+		t.Errorf("Expected tokenError, got %v", tok)
+	}
+	if b.String() != "Error reading rune: broken\n" {
+		t.Errorf("Unexpected diagnostics %q", b.String())
+	}
+}
+
 func runTokenTest(td []testData) error {
 	for _, tst := range td {
 		sr := strings.NewReader(tst.test)
-		lxr := newLexer(sr)
+		lxr := newLexer(sr, &Options{Diagnostics: io.Discard})
 		var tks []*token
 		getTokens(lxr, &tks)
 		if cmpTokenSlice(tst.expected, tks) == false {
@@ -223,7 +398,7 @@ func cmpTokenSlice(a []*token, b []*token) bool {
 			return false
 		} else {
 			switch v.typ {
-			case tokenComment, tokenAtom, tokenError:
+			case tokenComment, tokenAtom, tokenError, tokenString, tokenChar, tokenBool:
 				x, y := v, b[i]
 				if x.raw != y.raw || x.row != y.row || x.col != y.col {
 					return false
@@ -244,7 +419,7 @@ func cmpTokenSlice(a []*token, b []*token) bool {
 				} else {
 					return false
 				}
-			case tokenEOF, tokenLParen, tokenRParen, tokenQuote:
+			case tokenEOF, tokenLParen, tokenRParen, tokenQuote, tokenDatumComment, tokenDot, tokenVector, tokenHash:
 				x, y := v, b[i]
 				if x.row != y.row || x.col != y.col {
 					return false
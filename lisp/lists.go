@@ -0,0 +1,376 @@
+package lisp
+
+import (
+	"fmt"
+	"sort"
+)
+
+var listBuiltins = []*Builtin{
+	{"cons", builtinCons, 2, 2, "(cons x y)\nReturns a new pair of x and y."},
+	{"car", builtinCar, 1, 1, "(car pair)\nReturns the first element of pair."},
+	{"cdr", builtinCdr, 1, 1, "(cdr pair)\nReturns the second element of pair, the rest of a list."},
+	{"set-car!", builtinSetCar, 2, 2, "(set-car! pair x)\nSets the first element of pair to x."},
+	{"set-cdr!", builtinSetCdr, 2, 2, "(set-cdr! pair x)\nSets the second element of pair to x."},
+	{"pair?", builtinIsPair, 1, 1, "(pair? x)\nReports whether x is a pair."},
+	{"list", builtinList, 0, -1, "(list x ...)\nReturns a list of its arguments."},
+	{"length", builtinLength, 1, 1, "(length list)\nReturns the number of elements of list."},
+	{"append", builtinAppend, 0, -1, "(append list ... [tail])\nReturns the lists joined together, ending with tail."},
+	{"reverse", builtinReverse, 1, 1, "(reverse list)\nReturns the elements of list in reverse order."},
+	{"map", builtinMap, 2, -1, "(map f list1 list2 ...)\nReturns the results of calling f with the corresponding elements of each list, stopping at the shortest."},
+	{"filter", builtinFilter, 2, 2, "(filter pred list)\nReturns the elements of list for which pred is true."},
+	{"fold", builtinFold, 3, 3, "(fold f init list)\nCombines the elements of list from the left, calling (f acc x) with acc starting as init."},
+	{"reduce", builtinReduce, 2, 2, "(reduce f list)\nFolds f over list, starting with its first element."},
+	{"assoc", builtinAssoc, 2, 2, "(assoc key alist)\nReturns the first pair of alist whose car is equal to key, or #f."},
+	{"member", builtinMember, 2, 2, "(member x list)\nReturns the first tail of list whose car is equal to x, or #f."},
+	{"nth", builtinNth, 2, 2, "(nth n list)\nReturns the n'th element of list, counting from zero."},
+	{"last", builtinLast, 1, 1, "(last list)\nReturns the last element of list."},
+	{"sort", builtinSort, 1, 2, "(sort list [less])\nReturns the elements of list in ascending order as ordered by less, which defaults to <. The sort is stable."},
+}
+
+// toSlice returns the elements of the proper list v, or an error naming the
+// builtin name if v is improper or cyclic.
+func toSlice(name string, v Value) ([]Value, error) {
+	var vs []Value
+	// slow follows l at half its speed, so l meets it if the list is
+	// cyclic
+	slow := v
+	for l := v; l != nil; {
+		p, ok := l.(*Pair)
+		if !ok {
+			return nil, fmt.Errorf("%s expects a list, got %s", name, String(v))
+		}
+		vs = append(vs, p.Car)
+		l = p.Cdr
+		if len(vs)%2 == 0 {
+			slow = slow.(*Pair).Cdr
+		}
+		if l == slow {
+			return nil, fmt.Errorf("%s expects a list, got the cyclic list %s", name, String(v))
+		}
+	}
+	return vs, nil
+}
+
+func toPair(name string, v Value) (*Pair, error) {
+	p, ok := v.(*Pair)
+	if !ok {
+		return nil, fmt.Errorf("%s expects a pair, got %s", name, String(v))
+	}
+	return p, nil
+}
+
+func builtinCons(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("cons", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return &Pair{args[0], args[1]}, nil
+}
+
+func builtinCar(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("car", args, 1, 1); err != nil {
+		return nil, err
+	}
+	p, err := toPair("car", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return p.Car, nil
+}
+
+func builtinCdr(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("cdr", args, 1, 1); err != nil {
+		return nil, err
+	}
+	p, err := toPair("cdr", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return p.Cdr, nil
+}
+
+func builtinSetCar(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("set-car!", args, 2, 2); err != nil {
+		return nil, err
+	}
+	p, err := toPair("set-car!", args[0])
+	if err != nil {
+		return nil, err
+	}
+	p.Car = args[1]
+	return args[1], nil
+}
+
+func builtinSetCdr(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("set-cdr!", args, 2, 2); err != nil {
+		return nil, err
+	}
+	p, err := toPair("set-cdr!", args[0])
+	if err != nil {
+		return nil, err
+	}
+	p.Cdr = args[1]
+	return args[1], nil
+}
+
+func builtinIsPair(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("pair?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(*Pair)
+	return ok, nil
+}
+
+func builtinList(in *Interpreter, args []Value) (Value, error) {
+	return List(args...), nil
+}
+
+func builtinLength(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("length", args, 1, 1); err != nil {
+		return nil, err
+	}
+	vs, err := toSlice("length", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return len(vs), nil
+}
+
+// (append list ... tail) copies each list, ending with tail, which is
+// shared and need not be a list.
+func builtinAppend(in *Interpreter, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	var vs []Value
+	for _, a := range args[:len(args)-1] {
+		l, err := toSlice("append", a)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, l...)
+	}
+	v := args[len(args)-1]
+	for i := len(vs) - 1; i >= 0; i-- {
+		v = &Pair{vs[i], v}
+	}
+	return v, nil
+}
+
+func builtinReverse(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("reverse", args, 1, 1); err != nil {
+		return nil, err
+	}
+	vs, err := toSlice("reverse", args[0])
+	if err != nil {
+		return nil, err
+	}
+	var v Value
+	for _, x := range vs {
+		v = &Pair{x, v}
+	}
+	return v, nil
+}
+
+// (map f list ...) calls f with the corresponding elements of each list,
+// stopping at the end of the shortest.
+func builtinMap(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("map", args, 2, -1); err != nil {
+		return nil, err
+	}
+	var ls [][]Value
+	n := -1
+	for _, a := range args[1:] {
+		l, err := toSlice("map", a)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || len(l) < n {
+			n = len(l)
+		}
+		ls = append(ls, l)
+	}
+	vs := make([]Value, n)
+	for i := range vs {
+		fargs := make([]Value, len(ls))
+		for j, l := range ls {
+			fargs[j] = l[i]
+		}
+		var err error
+		if vs[i], err = in.apply(args[0], fargs); err != nil {
+			return nil, err
+		}
+	}
+	return List(vs...), nil
+}
+
+// (filter pred list) returns the elements of list for which pred is true.
+func builtinFilter(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("filter", args, 2, 2); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("filter", args[1])
+	if err != nil {
+		return nil, err
+	}
+	var vs []Value
+	for _, x := range l {
+		t, err := in.apply(args[0], []Value{x})
+		if err != nil {
+			return nil, err
+		}
+		if truthy(t) {
+			vs = append(vs, x)
+		}
+	}
+	return List(vs...), nil
+}
+
+// (fold f init list) combines the elements of list from the left, calling
+// (f acc x) with acc starting as init.
+func builtinFold(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("fold", args, 3, 3); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("fold", args[2])
+	if err != nil {
+		return nil, err
+	}
+	return foldLeft(in, args[0], args[1], l)
+}
+
+// (reduce f list) is fold with the first element of list as the initial
+// value. list must not be empty.
+func builtinReduce(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("reduce", args, 2, 2); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("reduce", args[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(l) == 0 {
+		return nil, fmt.Errorf("reduce expects a non-empty list")
+	}
+	return foldLeft(in, args[0], l[0], l[1:])
+}
+
+func foldLeft(in *Interpreter, f, acc Value, l []Value) (Value, error) {
+	for _, x := range l {
+		var err error
+		if acc, err = in.apply(f, []Value{acc, x}); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// (assoc key alist) returns the first pair of alist whose car is equal to
+// key, or #f.
+func builtinAssoc(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("assoc", args, 2, 2); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("assoc", args[1])
+	if err != nil {
+		return nil, err
+	}
+	for _, x := range l {
+		p, err := toPair("assoc", x)
+		if err != nil {
+			return nil, err
+		}
+		if equal(p.Car, args[0]) {
+			return p, nil
+		}
+	}
+	return false, nil
+}
+
+// (member x list) returns the first tail of list whose car is equal to x,
+// or #f.
+func builtinMember(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("member", args, 2, 2); err != nil {
+		return nil, err
+	}
+	if _, err := toSlice("member", args[1]); err != nil {
+		return nil, err
+	}
+	for l := args[1]; l != nil; l = l.(*Pair).Cdr {
+		if equal(l.(*Pair).Car, args[0]) {
+			return l, nil
+		}
+	}
+	return false, nil
+}
+
+// (nth n list) returns the n'th element of list, counting from zero.
+func builtinNth(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("nth", args, 2, 2); err != nil {
+		return nil, err
+	}
+	n, ok := args[0].(int)
+	if !ok {
+		return nil, fmt.Errorf("nth expects an integer index, got %s", String(args[0]))
+	}
+	l, err := toSlice("nth", args[1])
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n >= len(l) {
+		return nil, fmt.Errorf("nth: index %d out of range for list of length %d", n, len(l))
+	}
+	return l[n], nil
+}
+
+// (last list) returns the last element of list, which must not be empty.
+func builtinLast(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("last", args, 1, 1); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("last", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(l) == 0 {
+		return nil, fmt.Errorf("last expects a non-empty list")
+	}
+	return l[len(l)-1], nil
+}
+
+// (sort list [less]) returns the elements of list in ascending order as
+// decided by (less a b), which defaults to comparing numbers. The sort is
+// stable.
+func builtinSort(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("sort", args, 1, 2); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("sort", args[0])
+	if err != nil {
+		return nil, err
+	}
+	less := func(a, b Value) (bool, error) {
+		c, err := compare(a, b)
+		if err != nil {
+			return false, fmt.Errorf("sort: %v", err)
+		}
+		return c < 0, nil
+	}
+	if len(args) == 2 {
+		less = func(a, b Value) (bool, error) {
+			v, err := in.apply(args[1], []Value{a, b})
+			return truthy(v), err
+		}
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var ok bool
+		ok, err = less(l[i], l[j])
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return List(l...), nil
+}
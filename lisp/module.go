@@ -0,0 +1,289 @@
+package lisp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A module is a file evaluated by import in a global environment of its
+// own. Only the variables it exports are bound in the importing file.
+type module struct {
+	name    *Symbol
+	exports []*Symbol
+	env     map[*Symbol]Value
+}
+
+// EvalFile evaluates the file name as Eval does. The files it loads and
+// the modules it imports are looked for first in its directory.
+func (in *Interpreter) EvalFile(name string) (Value, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	return in.evalFile(path)
+}
+
+// evalFile evaluates the file path, which is absolute, in the current
+// global environment.
+func (in *Interpreter) evalFile(path string) (Value, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := in.dir
+	in.dir, in.loading = filepath.Dir(path), append(in.loading, path)
+	defer func() {
+		in.dir, in.loading = dir, in.loading[:len(in.loading)-1]
+	}()
+	v, err := in.Eval(bytes.NewReader(b))
+	if err != nil {
+		return nil, fileError(path, err)
+	}
+	return v, nil
+}
+
+// load evaluates the file path for load or import.
+func (in *Interpreter) load(path string) (Value, error) {
+	if err := cycle(in.loading, path); err != nil {
+		return nil, err
+	}
+	return in.evalFile(path)
+}
+
+// importModule evaluates the module in the file path, unless it has been
+// already.
+func (in *Interpreter) importModule(path string) (*module, error) {
+	if m, ok := in.modules[path]; ok {
+		return m, nil
+	}
+	name, exports, err := readModule(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &module{name, exports, map[*Symbol]Value{}}
+	for sym, v := range in.base {
+		m.env[sym] = v
+	}
+	global := in.global
+	in.global = m.env
+	_, err = in.load(path)
+	in.global = global
+	if err != nil {
+		return nil, err
+	}
+	in.modules[path] = m
+	return m, nil
+}
+
+// findFile returns the absolute path of the file name, looking for it in
+// dir, or the working directory if dir is empty, and then in the search
+// path. The extension .lisp may be left off.
+func (in *Interpreter) findFile(name, dir string) (string, error) {
+	dirs := []string{""}
+	if !filepath.IsAbs(name) {
+		dirs = append([]string{dir}, in.opts.path()...)
+	}
+	names := []string{name}
+	if filepath.Ext(name) == "" {
+		names = append(names, name+".lisp")
+	}
+	for _, d := range dirs {
+		for _, n := range names {
+			p := filepath.Join(d, n)
+			if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+				return filepath.Abs(p)
+			}
+		}
+	}
+	return "", fmt.Errorf("Cannot find %s", name)
+}
+
+// readFile parses the expressions of the file path.
+func readFile(path string) ([]*expr, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := newParser(newLexer(bytes.NewReader(b), &Options{Diagnostics: io.Discard}))
+	var es []*expr
+	for {
+		e, err := p.next()
+		if err == io.EOF {
+			return es, nil
+		}
+		if err != nil {
+			return nil, fileError(path, err)
+		}
+		es = append(es, e)
+	}
+}
+
+// readModule returns the name and exports of the module in the file path,
+// which must start with a module form.
+func readModule(path string) (*Symbol, []*Symbol, error) {
+	es, err := readFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(es) > 0 && es[0].isList() && es[0].first != nil && es[0].lit == 0 && es[0].first.symbol() == "module" {
+		if name, exports, msg := moduleArgs(es[0].items()[1:]); msg == "" {
+			var syms []*Symbol
+			for _, x := range exports {
+				syms = append(syms, x.sym())
+			}
+			return name, syms, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s is not a module: it does not start with (module name (export ...))", displayPath(path))
+}
+
+// moduleArgs checks the arguments of (module name (export name ...)),
+// returning a message if they are not of that form.
+func moduleArgs(args []*expr) (name *Symbol, exports []*expr, msg string) {
+	const form = "module expects the form (module name (export name ...))"
+	if len(args) != 2 || args[0].sym() == nil || !args[1].isList() || args[1].lit != 0 ||
+		args[1].first == nil || args[1].first.symbol() != "export" || args[1].dotted() != nil {
+		return nil, nil, form
+	}
+	exports = args[1].items()[1:]
+	for _, x := range exports {
+		if x.sym() == nil {
+			return nil, nil, form
+		}
+	}
+	return args[0].sym(), exports, ""
+}
+
+// importArgs checks the arguments of (import "path" [:as alias]),
+// returning a message if they are not of that form.
+func importArgs(args []*expr) (path string, alias *Symbol, msg string) {
+	if len(args) == 0 || args[0].isList() || args[0].atom.typ != tokenString {
+		return "", nil, `import expects the form (import "path" [:as alias])`
+	}
+	path = args[0].atom.val.(string)
+	switch {
+	case len(args) == 1:
+	case len(args) == 3 && args[1].symbol() == ":as" && args[2].sym() != nil:
+		alias = args[2].sym()
+	default:
+		return "", nil, `import expects the form (import "path" [:as alias])`
+	}
+	return path, alias, ""
+}
+
+// qualify returns the name under which a module imported as alias binds
+// its export name.
+func qualify(alias, name *Symbol) *Symbol {
+	return Intern(alias.Name + ":" + name.Name)
+}
+
+// cycle returns an error if path is already being loaded, as listed in
+// chain.
+func cycle(chain []string, path string) error {
+	for i, p := range chain {
+		if p == path {
+			var names []string
+			for _, p := range append(chain[i:len(chain):len(chain)], path) {
+				names = append(names, displayPath(p))
+			}
+			return fmt.Errorf("Cycle loading %s", strings.Join(names, " -> "))
+		}
+	}
+	return nil
+}
+
+// fileError records that err was raised by the file path. A syntax error
+// becomes an *Error, which the REPL does not take for incomplete input
+// when a file it loads ends inside an expression.
+func fileError(path string, err error) error {
+	if pe, ok := err.(*parseError); ok {
+		err = &Error{Row: pe.row, Col: pe.col, Msg: pe.msg}
+	}
+	setFile(err, displayPath(path))
+	return err
+}
+
+// displayPath returns path relative to the working directory if it is
+// within it.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func builtinLoad(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("load", args, 1, 1); err != nil {
+		return nil, err
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("load expects a string, got %s", String(args[0]))
+	}
+	path, err := in.findFile(name, in.dir)
+	if err != nil {
+		return nil, err
+	}
+	return in.load(path)
+}
+
+// (module name (export name ...))
+func (a *analyzer) analyzeModule(e *expr, args []*expr) node {
+	name, exports, msg := moduleArgs(args)
+	if msg != "" {
+		return a.errorf(e, "%s", msg)
+	}
+	if a.scope != nil {
+		return a.errorf(e, "module must be at the top level")
+	}
+	for _, x := range exports {
+		if !a.bound(x.sym()) {
+			a.errorf(x, "%s is exported but not defined", x.sym())
+		}
+	}
+	return &constNode{atExpr(e), name}
+}
+
+// (import "path" [:as alias])
+//
+// Importing evaluates the module once, binding alias:name in the current
+// global environment to the value of each name it exports. The alias
+// defaults to the name of the module.
+func (a *analyzer) analyzeImport(e *expr, args []*expr) node {
+	file, alias, msg := importArgs(args)
+	if msg != "" {
+		return a.errorf(e, "%s", msg)
+	}
+	if a.scope != nil {
+		return a.errorf(e, "import must be at the top level")
+	}
+	path, err := a.in.findFile(file, a.in.dir)
+	if err != nil {
+		// globalDefines has reported it
+		return &constNode{at: atExpr(e)}
+	}
+	env := a.in.global
+	imp := &Builtin{Name: "import", Fn: func(in *Interpreter, args []Value) (Value, error) {
+		m, err := in.importModule(path)
+		if err != nil {
+			return nil, err
+		}
+		as := alias
+		if as == nil {
+			as = m.name
+		}
+		for _, x := range m.exports {
+			env[qualify(as, x)] = m.env[x]
+		}
+		return m.name, nil
+	}}
+	return &callNode{at: atExpr(e), f: &constNode{atExpr(e), imp}}
+}
@@ -0,0 +1,135 @@
+package lisp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var moduleFiles = map[string]string{
+	"lib/geo.lisp": `(module geometry (export area square))
+(define pi 3)
+(define (square x) (* x x))
+(define (area r) (* pi (square r)))
+(display "geo ")`,
+	"lib/shapes.lisp": `(module shapes (export unit))
+(import "geo" :as g)
+(define (unit) (g:area 1))`,
+	"path/util.lisp": `(define (twice f x) (f (f x)))`,
+	"a.lisp":         `(load "b")`,
+	"b.lisp":         `(load "a.lisp")`,
+	"m1.lisp": `(module m1 (export f))
+(import "m2")
+(define (f) 1)`,
+	"m2.lisp": `(module m2 (export g))
+(import "m1")
+(define (g) 1)`,
+	"bad.lisp":    `(module bad (export missing))`,
+	"plain.lisp":  `(define plain 1)`,
+	"broken.lisp": `(define (f x) (car x)) (f 1)`,
+	"lib/pairs.lisp": `(define (head x)
+  (car x))`,
+	"uses.lisp": `(load "lib/pairs")
+
+(head 5)`,
+	"unclosed.lisp": `(define x`,
+	"main.lisp": `(load "util")
+(import "lib/geo" :as g)
+(define pi 100)
+(list (g:area 2) (twice g:square 3) (g:square 4))`,
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range moduleFiles {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		file     string // Evaluated by EvalFile, or "" for test
+		test     string
+		expected string // Printed result and output, or the error message
+	}{
+		{"main.lisp", "", "geo (12 81 16)"},
+		{"", `(load "plain") plain`, "1"},
+		{"", `(import "lib/geo.lisp") (geometry:square 3)`, "geo 9"},
+		{"", `(import "lib/shapes") (list (shapes:unit) (shapes:unit))`, "geo (3 3)"},
+		{"", `(import "lib/geo" :as a) (import "lib/geo" :as b) (eq? a:area b:area)`, "geo #t"},
+		{"", `(import "lib/geo" :as g) g:pi`, "1:26: Unbound variable g:pi"},
+		{"", `(import "lib/geo" :as g) (define (f) (g:area 1)) (f)`, "geo 3"},
+		{"", `(load "missing")`, "1:1: Cannot find missing"},
+		{"", `(import "plain")`, "1:1: plain.lisp is not a module: it does not start with (module name (export ...))"},
+		{"", `(import "bad")`, "bad.lisp:1:21: missing is exported but not defined"},
+		{"", `(import "m1")`, "m2.lisp:2:1: Cycle loading m1.lisp -> m2.lisp -> m1.lisp"},
+		{"a.lisp", "", "b.lisp:1:1: Cycle loading a.lisp -> b.lisp -> a.lisp"},
+		{"", `(load "broken")`, "broken.lisp:1:15: car expects a pair, got 1\n\tin f 1:24"},
+		{"uses.lisp", "", "lib/pairs.lisp:2:3: car expects a pair, got 5\n\tin head 3:1"},
+		{"", `(load "unclosed")`, "unclosed.lisp:1:10: Expecting ')' encountered EOF"},
+		{"", `(import plain)`, `1:1: import expects the form (import "path" [:as alias])`},
+		{"", `(import "lib/geo" as g)`, `1:1: import expects the form (import "path" [:as alias])`},
+		{"", `(define (f) (import "lib/geo"))`, "1:13: import must be at the top level"},
+		{"", `(module m (export))`, "m"},
+		{"", `(module m export)`, "1:1: module expects the form (module name (export name ...))"},
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	for _, eng := range engines {
+		for _, tst := range tests {
+			var out strings.Builder
+			in := New(&Options{Stdout: &out, Engine: eng, Path: []string{filepath.Join(dir, "path"), filepath.Join(dir, "lib")}})
+			var (
+				v   Value
+				err error
+			)
+			if tst.file != "" {
+				v, err = in.EvalFile(tst.file)
+			} else {
+				v, err = in.EvalString(tst.test)
+			}
+			got := out.String() + String(v)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tst.expected {
+				t.Errorf("For %s%s with engine %d\nExpected:\t%s\nGot:\t\t%s", tst.file, tst.test, eng, tst.expected, got)
+			}
+		}
+	}
+}
+
+func TestErrorFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"lib.lisp":      "(define (head x)\n  (car x))",
+		"main.lisp":     "(load \"lib\")\n(head 5)",
+		"unclosed.lisp": "(define x",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, eng := range engines {
+		in := New(&Options{Engine: eng})
+		_, err := in.EvalFile(filepath.Join(dir, "main.lisp"))
+		le, ok := err.(*Error)
+		if !ok || filepath.Base(le.File) != "lib.lisp" || le.Row != 2 || le.Col != 3 {
+			t.Errorf("With engine %d expected an error at lib.lisp:2:3, got %#v", eng, err)
+		}
+		// The load is checked before the program runs
+		_, err = in.EvalString(`(load "` + filepath.Join(dir, "unclosed.lisp") + `")`)
+		if l, ok := err.(ErrorList); !ok || len(l) != 1 || filepath.Base(l[0].File) != "unclosed.lisp" || errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("With engine %d expected a complete error in unclosed.lisp, got %v", eng, err)
+		}
+	}
+}
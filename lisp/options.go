@@ -0,0 +1,126 @@
+package lisp
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Options controls where the lexer, parser and interpreter read from and
+// write to. A nil *Options, or a zero field, falls back to the process's
+// standard streams.
+type Options struct {
+	// Diagnostics receives lexer and parser diagnostics such as rune read
+	// errors. Set it to io.Discard to silence them.
+	Diagnostics io.Writer
+
+	// Stdout receives what the printing builtins such as display write. It
+	// defaults to os.Stdout.
+	Stdout io.Writer
+
+	// Stderr receives what warn prints. It defaults to os.Stderr.
+	Stderr io.Writer
+
+	// Stdin is read by read-line and read. It defaults to os.Stdin. A
+	// *bufio.Reader is used as it is, so it may be shared with other
+	// readers of the same input without either reading ahead of the other.
+	Stdin io.Reader
+
+	// MaxDepth limits how deeply evaluation may nest before it fails with
+	// an *Error instead of exhausting the Go stack. Tail calls do not
+	// nest. Zero means DefaultMaxDepth.
+	MaxDepth int
+
+	// Engine selects how programs are executed.
+	Engine Engine
+
+	// Path lists the directories searched for the files load and import
+	// name by relative paths, after the directory of the file naming them.
+	// Nil means the directories listed in $LISPPATH.
+	Path []string
+
+	// NoPrelude leaves out the prelude, so that the interpreter starts with
+	// only the builtins.
+	NoPrelude bool
+
+	// Prelude, if not empty, is evaluated instead of the standard Prelude.
+	Prelude string
+}
+
+// Engine selects how an Interpreter executes programs. Both engines share
+// the builtins and global environment and produce the same results.
+type Engine int
+
+const (
+	// TreeWalker evaluates parsed expressions directly.
+	TreeWalker Engine = iota
+	// BytecodeVM compiles each top level expression to bytecode, with
+	// local variables resolved to frame offsets, and runs it on a stack
+	// machine.
+	BytecodeVM
+)
+
+// DefaultMaxDepth is the evaluation depth limit used when Options.MaxDepth
+// is zero.
+const DefaultMaxDepth = 10000
+
+func (o *Options) diagnostics() io.Writer {
+	if o == nil || o.Diagnostics == nil {
+		return os.Stderr
+	}
+	return o.Diagnostics
+}
+
+func (o *Options) stdout() io.Writer {
+	if o == nil || o.Stdout == nil {
+		return os.Stdout
+	}
+	return o.Stdout
+}
+
+func (o *Options) stderr() io.Writer {
+	if o == nil || o.Stderr == nil {
+		return os.Stderr
+	}
+	return o.Stderr
+}
+
+func (o *Options) stdin() io.Reader {
+	if o == nil || o.Stdin == nil {
+		return os.Stdin
+	}
+	return o.Stdin
+}
+
+func (o *Options) maxDepth() int {
+	if o == nil || o.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return o.MaxDepth
+}
+
+func (o *Options) path() []string {
+	if o == nil || o.Path == nil {
+		return filepath.SplitList(os.Getenv("LISPPATH"))
+	}
+	return o.Path
+}
+
+func (o *Options) prelude() string {
+	switch {
+	case o == nil:
+		return Prelude
+	case o.NoPrelude:
+		return ""
+	case o.Prelude != "":
+		return o.Prelude
+	}
+	return Prelude
+}
+
+func (o *Options) engine() Engine {
+	if o == nil {
+		return TreeWalker
+	}
+	return o.Engine
+}
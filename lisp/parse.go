@@ -1,31 +1,284 @@
 package lisp
 
-type parseError error
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+type parseError struct {
+	row, col int
+	msg      string
+	eof      bool // The input ended inside an expression
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.row, e.col, e.msg)
+}
+
+func (e *parseError) Is(target error) bool {
+	return e.eof && target == io.ErrUnexpectedEOF
+}
 
 type parser struct {
 	l *lexer
+	// The datum labels defined so far in the current top level expression
+	labels map[int]bool
 }
 
 func newParser(l *lexer) *parser {
-	return &parser{l: l}
+	return &parser{l: l, labels: map[int]bool{}}
 }
 
+// An expr is either an atom or a list cell. A list is a chain of cells
+// linked through rest, each holding one element in first; the empty list
+// is a cell with neither first nor rest. The last cell of an improper list
+// such as (a . b) holds the expression after the dot in tail. The first
+// cell of a list records the position of its opening parenthesis and just
+// after its closing one, and in lit whether it opened a #( vector or
+// #hash( table literal.
 type expr struct {
 	first *expr
 	atom  *token
 	rest  *expr
+	tail  *expr
+	lit   tokenTyp
+	// label is the n of a #n= label on e, which is labelled if present
+	label          int
+	labelled       bool
+	row, col       int
+	endRow, endCol int
+}
+
+// pos returns the source position of e.
+func (e *expr) pos() (int, int) {
+	if e.atom != nil {
+		return e.atom.row, e.atom.col
+	}
+	return e.row, e.col
+}
+
+// end returns the source position just after e.
+func (e *expr) end() (int, int) {
+	if e.atom == nil {
+		return e.endRow, e.endCol
+	}
+	raw := e.atom.raw
+	if i := strings.LastIndexByte(raw, '\n'); i >= 0 {
+		return e.atom.row + strings.Count(raw, "\n"), utf8.RuneCountInString(raw[i+1:]) + 1
+	}
+	return e.atom.row, e.atom.col + utf8.RuneCountInString(raw)
+}
+
+func (e *expr) isList() bool {
+	return e.atom == nil
+}
+
+// items returns the elements of the list e.
+func (e *expr) items() []*expr {
+	var s []*expr
+	for c := e; c != nil && c.first != nil; c = c.rest {
+		s = append(s, c.first)
+	}
+	return s
 }
 
-func (p *parser) parseSExpr() (*expr, error) {
+// dotted returns the expression after the dot of the improper list e, or
+// nil if e is a proper list.
+func (e *expr) dotted() *expr {
+	for ; e.rest != nil; e = e.rest {
+	}
+	return e.tail
+}
+
+// symbol returns the name of the atom e, or "" if e is not a symbol.
+func (e *expr) symbol() string {
+	if e.atom == nil || e.atom.typ != tokenAtom {
+		return ""
+	}
+	return e.atom.val.(*Symbol).Name
+}
+
+// sym returns the symbol e, or nil if e is not a symbol.
+func (e *expr) sym() *Symbol {
+	if e.atom == nil || e.atom.typ != tokenAtom {
+		return nil
+	}
+	return e.atom.val.(*Symbol)
+}
+
+// next returns the next top level expression, or io.EOF once the input is
+// exhausted.
+func (p *parser) next() (*expr, error) {
+	t, err := p.nextToken()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ == tokenEOF {
+		return nil, io.EOF
+	}
+	p.labels = map[int]bool{}
+	return p.parseSExpr(t)
+}
+
+// nextToken returns the next token that is not a comment, discarding the
+// datum following each #;.
+func (p *parser) nextToken() (*token, error) {
 	for {
 		t := p.l.next()
 		switch t.typ {
-		case tokenLParen:
-			//f, err := p.parseSExpr()
-			t = p.l.next()
-			if t.typ != tokenRParen {
-				//return &expr{first: f	}, fmt.Errorf("Expecting ')' encountered '%v'", t.val
+		case tokenComment:
+			continue
+		case tokenDatumComment:
+			d, err := p.nextToken()
+			if err != nil {
+				return nil, err
 			}
+			if d.typ == tokenEOF || d.typ == tokenRParen {
+				return nil, &parseError{d.row, d.col, "Expecting datum after #;", d.typ == tokenEOF}
+			}
+			if _, err := p.parseSExpr(d); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return t, nil
+	}
+}
+
+func (p *parser) parseSExpr(t *token) (*expr, error) {
+	switch t.typ {
+	case tokenLParen, tokenVector, tokenHash:
+		return p.parseList(t)
+	case tokenRParen:
+		return nil, &parseError{t.row, t.col, "Unexpected ')'", false}
+	case tokenDot:
+		return nil, &parseError{t.row, t.col, "Unexpected '.'", false}
+	case tokenQuote:
+		q, err := p.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if q.typ == tokenEOF {
+			return nil, &parseError{q.row, q.col, "Unexpected EOF after quote", true}
+		}
+		e, err := p.parseSExpr(q)
+		if err != nil {
+			return nil, err
+		}
+		sym := &token{typ: tokenAtom, val: Intern("quote"), raw: t.raw, row: t.row, col: t.col}
+		qe := &expr{first: &expr{atom: sym}, rest: &expr{first: e}, row: t.row, col: t.col}
+		qe.endRow, qe.endCol = e.end()
+		return qe, nil
+	case tokenLabel:
+		n := t.val.(int)
+		if p.labels[n] {
+			return nil, &parseError{t.row, t.col, fmt.Sprintf("Duplicate datum label %s", t.raw), false}
+		}
+		p.labels[n] = true
+		d, err := p.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if d.typ == tokenEOF || d.typ == tokenRParen || d.typ == tokenLabelRef && d.val == n {
+			return nil, &parseError{d.row, d.col, fmt.Sprintf("Expecting datum after %s", t.raw), d.typ == tokenEOF}
+		}
+		e, err := p.parseSExpr(d)
+		if err != nil {
+			return nil, err
+		}
+		e.label, e.labelled = n, true
+		return e, nil
+	case tokenLabelRef:
+		if !p.labels[t.val.(int)] {
+			return nil, &parseError{t.row, t.col, fmt.Sprintf("Undefined datum label %s", t.raw), false}
+		}
+	case tokenEOF:
+		return nil, &parseError{t.row, t.col, "Unexpected EOF", true}
+	case tokenError:
+		return nil, &parseError{t.row, t.col, t.err, false}
+	}
+	return &expr{atom: t}, nil
+}
+
+// parseList parses the elements of a list whose '(' token lp has been
+// consumed.
+func (p *parser) parseList(lp *token) (*expr, error) {
+	head := &expr{row: lp.row, col: lp.col}
+	if lp.typ != tokenLParen {
+		head.lit = lp.typ
+	}
+	tail := head
+	for {
+		t, err := p.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if t.typ == tokenRParen {
+			if err := p.checkLiteral(head); err != nil {
+				return nil, err
+			}
+			head.endRow, head.endCol = t.row, t.col+1
+			return head, nil
+		}
+		if t.typ == tokenEOF {
+			return nil, &parseError{t.row, t.col, "Expecting ')' encountered EOF", true}
+		}
+		if t.typ == tokenDot && head.first != nil && head.lit == 0 {
+			rp, err := p.parseDotted(tail)
+			if err != nil {
+				return nil, err
+			}
+			head.endRow, head.endCol = rp.row, rp.col+1
+			return head, nil
+		}
+		e, err := p.parseSExpr(t)
+		if err != nil {
+			return nil, err
+		}
+		if tail.first != nil {
+			tail.rest = &expr{}
+			tail = tail.rest
+		}
+		tail.first = e
+	}
+}
+
+// parseDotted parses the rest of an improper list after its '.', storing
+// the final expression in tail, the list's last cell, and returns the
+// closing ')'.
+func (p *parser) parseDotted(tail *expr) (*token, error) {
+	t, err := p.nextToken()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ == tokenRParen || t.typ == tokenEOF {
+		return nil, &parseError{t.row, t.col, "Expecting datum after '.'", t.typ == tokenEOF}
+	}
+	if tail.tail, err = p.parseSExpr(t); err != nil {
+		return nil, err
+	}
+	if t, err = p.nextToken(); err != nil {
+		return nil, err
+	}
+	if t.typ != tokenRParen {
+		return nil, &parseError{t.row, t.col, "Expecting ')' after dotted tail", t.typ == tokenEOF}
+	}
+	return t, nil
+}
+
+// checkLiteral checks that each entry of a #hash( literal is a (key . value)
+// pair.
+func (p *parser) checkLiteral(e *expr) error {
+	if e.lit != tokenHash {
+		return nil
+	}
+	for _, kv := range e.items() {
+		if !kv.isList() || len(kv.items()) != 1 || kv.tail == nil {
+			row, col := kv.pos()
+			return &parseError{row, col, "Expecting (key . value) in #hash", false}
 		}
 	}
+	return nil
 }
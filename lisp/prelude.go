@@ -0,0 +1,77 @@
+package lisp
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Prelude is the source of the standard prelude, procedures written in
+// Lisp that New evaluates after defining the builtins.
+//
+//go:embed prelude.lisp
+var Prelude string
+
+// preludeFile is the name errors give the file of the prelude.
+const preludeFile = "<prelude>"
+
+// evalPrelude evaluates src in the base environment, which modules start
+// with, and then copies it into the global environment.
+func (in *Interpreter) evalPrelude(src string) error {
+	in.global = in.base
+	in.loading = append(in.loading, preludeFile)
+	_, err := in.EvalString(src)
+	in.loading = in.loading[:len(in.loading)-1]
+	if err != nil {
+		err = fileError(preludeFile, err)
+	}
+	in.global = map[*Symbol]Value{}
+	for sym, v := range in.base {
+		in.global[sym] = v
+	}
+	return err
+}
+
+var (
+	standardOnce sync.Once
+	standardIn   *Interpreter
+	preludeDocs  map[string]string
+)
+
+// standard returns an interpreter holding the builtins and the standard
+// prelude, which programs are checked against without being run. Nothing
+// else is ever evaluated in it.
+func standard() *Interpreter {
+	standardOnce.Do(func() {
+		standardIn = New(&Options{
+			Diagnostics: io.Discard,
+			Stdout:      io.Discard,
+			Stderr:      io.Discard,
+			Stdin:       strings.NewReader(""),
+			NoPrelude:   true,
+		})
+		if err := standardIn.evalPrelude(Prelude); err != nil {
+			panic(fmt.Sprintf("lisp: prelude: %v", err))
+		}
+		preludeDocs = map[string]string{}
+		for sym, v := range standardIn.global {
+			if _, ok := builtinIndex[sym.Name]; ok {
+				continue
+			}
+			if d, ok := procDoc(v); ok {
+				preludeDocs[sym.Name] = d
+			}
+		}
+	})
+	return standardIn
+}
+
+// preludeDoc returns the documentation of the procedure name defined by
+// the standard prelude.
+func preludeDoc(name string) (string, bool) {
+	standard()
+	d, ok := preludeDocs[name]
+	return d, ok
+}
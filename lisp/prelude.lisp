@@ -0,0 +1,110 @@
+; The standard prelude: procedures written in Lisp that every interpreter
+; starts with unless Options.NoPrelude is set.
+
+; Numbers
+
+(define (zero? x)
+  "Reports whether x is zero."
+  (= x 0))
+
+(define (positive? x)
+  "Reports whether x is greater than zero."
+  (> x 0))
+
+(define (negative? x)
+  "Reports whether x is less than zero."
+  (< x 0))
+
+(define (even? n)
+  "Reports whether the integer n is even."
+  (= (mod n 2) 0))
+
+(define (odd? n)
+  "Reports whether the integer n is odd."
+  (not (even? n)))
+
+(define (abs x)
+  "Returns the absolute value of x."
+  (if (< x 0) (- x) x))
+
+(define (min x . xs)
+  "Returns the least of its arguments."
+  (fold (lambda (a b) (if (< b a) b a)) x xs))
+
+(define (max x . xs)
+  "Returns the greatest of its arguments."
+  (fold (lambda (a b) (if (> b a) b a)) x xs))
+
+; Lists
+
+(define (cadr xs)
+  "Returns the second element of xs, (car (cdr xs))."
+  (car (cdr xs)))
+
+(define (cddr xs)
+  "Returns (cdr (cdr xs))."
+  (cdr (cdr xs)))
+
+(define (caddr xs)
+  "Returns the third element of xs, (car (cdr (cdr xs)))."
+  (car (cdr (cdr xs))))
+
+(define (for-each f xs)
+  "Calls f with each element of xs in turn, for its effects."
+  (if (null? xs)
+      nil
+      (do
+        (f (car xs))
+        (for-each f (cdr xs)))))
+
+(define (any pred xs)
+  "Returns the first true result of calling pred with the elements of xs, or #f if there is none."
+  (if (null? xs)
+      false
+      (or (pred (car xs)) (any pred (cdr xs)))))
+
+(define (every pred xs)
+  "Reports whether pred is true of every element of xs."
+  (if (null? xs)
+      true
+      (and (pred (car xs)) (every pred (cdr xs)))))
+
+(define (remove pred xs)
+  "Returns the elements of xs for which pred is false."
+  (filter (lambda (x) (not (pred x))) xs))
+
+(define (count pred xs)
+  "Returns the number of elements of xs for which pred is true."
+  (fold (lambda (n x) (if (pred x) (+ n 1) n)) 0 xs))
+
+(define (take n xs)
+  "Returns the first n elements of xs, or all of them if there are fewer."
+  (define (loop n xs acc)
+    (if (or (<= n 0) (null? xs))
+        (reverse acc)
+        (loop (- n 1) (cdr xs) (cons (car xs) acc))))
+  (loop n xs nil))
+
+(define (drop n xs)
+  "Returns xs without its first n elements."
+  (if (or (<= n 0) (null? xs))
+      xs
+      (drop (- n 1) (cdr xs))))
+
+(define (range start end)
+  "Returns the integers from start up to but not including end."
+  (define (loop i acc)
+    (if (< i start)
+        acc
+        (loop (- i 1) (cons i acc))))
+  (loop (- end 1) nil))
+
+; Procedures
+
+(define (identity x)
+  "Returns x."
+  x)
+
+(define (compose f g)
+  "Returns a procedure of one argument x that returns (f (g x)), calling g first and then f."
+  (lambda (x) (f (g x))))
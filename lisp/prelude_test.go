@@ -0,0 +1,91 @@
+package lisp
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestPrelude(t *testing.T) {
+	if errs := Vet([]byte(Prelude)); errs != nil {
+		t.Errorf("Vetting the prelude: %v", errs)
+	}
+	tests := []evalData{
+		{"(list (zero? 0) (positive? -1) (negative? -1) (even? 4) (odd? 4))", "(#t #f #t #t #f)"},
+		{"(list (abs -3) (abs 2.5) (min 3 1 2) (max 3 1 2) (max 7))", "(3 2.5 1 3 7)"},
+		{"(list (cadr '(1 2 3)) (cddr '(1 2 3)) (caddr '(1 2 3)))", "(2 (3) 3)"},
+		{"(define n 0) (for-each (lambda (x) (set! n (+ n x))) '(1 2 3)) n", "6"},
+		{"(list (any even? '(1 2)) (any even? nil) (every odd? '(1 3)) (every odd? '(1 2)))", "(#t #f #t #f)"},
+		{"(list (remove odd? '(1 2 3 4)) (count odd? '(1 2 3)))", "((2 4) 2)"},
+		{"(list (take 2 '(1 2 3)) (take 5 '(1)) (drop 2 '(1 2 3)) (drop 5 '(1)))", "((1 2) (1) (3) ())"},
+		{"(list (range 0 5) (range 3 3) (length (range 0 100000)))", "((0 1 2 3 4) () 100000)"},
+		// None of them recurses deeper than the depth limit on long lists
+		{"(list (length (take 20000 (range 0 30000))) (length (drop 20000 (range 0 30000))))", "(20000 10000)"},
+		{"(define xs (range 1 30000)) (list (any negative? xs) (every positive? xs) (count odd? xs))", "(#f #t 15000)"},
+		{"(define n 0) (for-each (lambda (x) (set! n (+ n 1))) (range 0 30000)) n", "30000"},
+		{"(list (identity 1) ((compose abs -) 3))", "(1 3)"},
+		// The prelude keeps its own definitions
+		{"(define (even? n) 'no) (odd? 3)", "#t"},
+		{"(doc 'abs)", `"(abs x)\nReturns the absolute value of x."`},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+	if d, _ := Doc("range"); d != "(range start end)\nReturns the integers from start up to but not including end." {
+		t.Errorf("Doc of range: %q", d)
+	}
+}
+
+func TestPreludeErrorFile(t *testing.T) {
+	for _, eng := range engines {
+		_, err := New(&Options{Engine: eng}).EvalString("(take 'a '(1))")
+		if le, ok := err.(*Error); !ok || le.File != "<prelude>" {
+			t.Errorf("With engine %d expected an error in <prelude>, got %v", eng, err)
+		}
+	}
+}
+
+func TestPreludeOptions(t *testing.T) {
+	tests := []struct {
+		opts     Options
+		test     string
+		expected string
+	}{
+		{Options{NoPrelude: true}, "(abs -1)", "1:2: Unbound variable abs"},
+		{Options{NoPrelude: true}, "(car '(1))", "1"},
+		{Options{Prelude: "(define (twice x) (* 2 x))"}, "(twice 2)", "4"},
+		{Options{Prelude: "(define (twice x) (* 2 x))"}, "(abs -1)", "1:2: Unbound variable abs"},
+		{Options{Prelude: "(define x"}, "", "<prelude>:1:10: Expecting ')' encountered EOF"},
+		{Options{Prelude: "(car 1)"}, "", "<prelude>:1:1: car expects a pair, got 1"},
+	}
+	for _, eng := range engines {
+		for _, tst := range tests {
+			opts := tst.opts
+			opts.Diagnostics, opts.Stdout, opts.Engine = io.Discard, io.Discard, eng
+			got := func() string {
+				in, err := Start(&opts)
+				if err != nil {
+					return err.Error()
+				}
+				v, err := in.EvalString(tst.test)
+				if err != nil {
+					return err.Error()
+				}
+				return String(v)
+			}()
+			if got != tst.expected {
+				t.Errorf("For %s with prelude %q and engine %d\nExpected:\t%s\nGot:\t\t%s", tst.test, tst.opts.Prelude, eng, tst.expected, got)
+			}
+		}
+	}
+}
+
+func TestNewPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); fmt.Sprint(r) != "lisp: <prelude>:1:1: car expects a pair, got 1" {
+			t.Errorf("Unexpected panic %v", r)
+		}
+	}()
+	New(&Options{Prelude: "(car 1)", Stdout: io.Discard})
+	t.Error("New did not panic")
+}
@@ -0,0 +1,178 @@
+package lisp
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultWidth is the line width PrettyPrint uses when given a width of
+// zero or less.
+const DefaultWidth = 80
+
+// bodyForms gives, for the special forms whose bodies are indented rather
+// than aligned, how many arguments stay on the first line.
+var bodyForms = map[string]int{
+	"define": 1,
+	"lambda": 1,
+	"let":    1,
+	"switch": 1,
+	"do":     0,
+}
+
+// PrettyPrint writes v to w as Write does, but breaks lists that do not
+// fit within width columns across lines. The bodies of special forms such
+// as define and lambda are indented by two columns under the form, and the
+// arguments of other calls are aligned under the first argument.
+func PrettyPrint(w io.Writer, v Value, width int) error {
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	pp := &prettyPrinter{printer: newPrinter(v, false), width: width}
+	pp.pretty(v)
+	_, err := io.WriteString(w, pp.b.String())
+	return err
+}
+
+type prettyPrinter struct {
+	*printer
+	width int
+	col   int // The column the next rune is written at
+}
+
+func (pp *prettyPrinter) write(s string) {
+	pp.b.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		pp.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		pp.col += utf8.RuneCountInString(s)
+	}
+}
+
+// newline starts a new line indented to col.
+func (pp *prettyPrinter) newline(col int) {
+	pp.write("\n" + strings.Repeat(" ", col))
+}
+
+// flat returns v written on one line, and the labels that would be
+// defined once it had been written.
+func (pp *prettyPrinter) flat(v Value) (string, map[Value]int) {
+	p := &printer{cycles: pp.cycles, labels: map[Value]int{}}
+	for k, n := range pp.labels {
+		p.labels[k] = n
+	}
+	p.value(v)
+	return p.b.String(), p.labels
+}
+
+// label writes the label of v as printer.label does.
+func (pp *prettyPrinter) label(v Value) bool {
+	start := pp.b.Len()
+	ref := pp.printer.label(v)
+	pp.col += utf8.RuneCountInString(pp.b.String()[start:])
+	return ref
+}
+
+func (pp *prettyPrinter) pretty(v Value) {
+	s, labels := pp.flat(v)
+	switch v.(type) {
+	case *Pair, *Vector, *Hash:
+	default:
+		pp.write(s)
+		pp.labels = labels
+		return
+	}
+	if pp.col+utf8.RuneCountInString(s) <= pp.width {
+		pp.write(s)
+		pp.labels = labels
+		return
+	}
+	if pp.label(v) {
+		return
+	}
+	switch v := v.(type) {
+	case *Pair:
+		pp.list(v)
+	case *Vector:
+		pp.write("#(")
+		pp.align(v.Items, pp.col)
+		pp.write(")")
+	case *Hash:
+		pp.write("#hash(")
+		col := pp.col
+		for i, k := range v.keys {
+			if i > 0 {
+				pp.newline(col)
+			}
+			// Each entry is written as a dotted pair even when the value
+			// is a list, as print does
+			pp.write("(")
+			pp.pretty(k)
+			pp.write(" . ")
+			pp.pretty(v.vals[i])
+			pp.write(")")
+		}
+		pp.write(")")
+	}
+}
+
+// align writes vs on separate lines starting at col.
+func (pp *prettyPrinter) align(vs []Value, col int) {
+	for i, x := range vs {
+		if i > 0 {
+			pp.newline(col)
+		}
+		pp.pretty(x)
+	}
+}
+
+func (pp *prettyPrinter) list(v *Pair) {
+	var items []Value
+	for {
+		items = append(items, v.Car)
+		next, ok := v.Cdr.(*Pair)
+		if !ok || pp.cycles[next] {
+			break
+		}
+		v = next
+	}
+	pp.write("(")
+	open := pp.col - 1
+	col := pp.col
+	if sym, ok := items[0].(*Symbol); ok && len(items) > 1 {
+		pp.write(sym.Name)
+		if n, ok := bodyForms[sym.Name]; ok {
+			// Distinguished arguments on the first line, then the body
+			if n > len(items)-1 {
+				n = len(items) - 1
+			}
+			for _, x := range items[1 : 1+n] {
+				pp.write(" ")
+				pp.pretty(x)
+			}
+			col = open + 2
+			for _, x := range items[1+n:] {
+				pp.newline(col)
+				pp.pretty(x)
+			}
+		} else {
+			// A call, with its arguments aligned unless that leaves
+			// them too little room
+			if pp.col+1 > open+pp.width/2 {
+				pp.newline(open + 2)
+			} else {
+				pp.write(" ")
+			}
+			col = pp.col
+			pp.align(items[1:], col)
+		}
+	} else {
+		pp.align(items, col)
+	}
+	if v.Cdr != nil {
+		pp.newline(col)
+		pp.write(". ")
+		pp.pretty(v.Cdr)
+	}
+	pp.write(")")
+}
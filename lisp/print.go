@@ -0,0 +1,239 @@
+package lisp
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// String returns the printed representation of v, as written by Write.
+func String(v Value) string {
+	p := newPrinter(v, false)
+	p.value(v)
+	return p.b.String()
+}
+
+// Write writes v to w in a form that reads back as an equal value. Strings
+// and characters are written as literals, and structure that contains
+// itself is written with #n= labels and #n# references to them.
+// Procedures and symbols that are not valid atoms have no readable form.
+func Write(w io.Writer, v Value) error {
+	_, err := io.WriteString(w, String(v))
+	return err
+}
+
+// Print writes v to w as display does, which is as Write does except that
+// strings and characters are written bare, at any depth.
+func Print(w io.Writer, v Value) error {
+	p := newPrinter(v, true)
+	p.value(v)
+	_, err := io.WriteString(w, p.b.String())
+	return err
+}
+
+type printer struct {
+	b       strings.Builder
+	display bool
+	// cycles holds the pairs, vectors and hash tables that contain
+	// themselves, which are labelled where they are first written.
+	cycles map[Value]bool
+	labels map[Value]int
+}
+
+func newPrinter(v Value, display bool) *printer {
+	p := &printer{display: display, cycles: map[Value]bool{}, labels: map[Value]int{}}
+	p.scan(v, map[Value]bool{}, map[Value]bool{})
+	return p
+}
+
+// scan finds the values within v that are reached again from inside
+// themselves. active holds the values v is inside of and done those
+// already scanned. The cdrs of a list are followed in a loop so that long
+// lists do not recurse deeply.
+func (p *printer) scan(v Value, active, done map[Value]bool) {
+	switch x := v.(type) {
+	case *Pair:
+		var chain []Value
+		for {
+			if active[x] {
+				p.cycles[x] = true
+				break
+			}
+			if done[x] {
+				break
+			}
+			active[x] = true
+			chain = append(chain, x)
+			p.scan(x.Car, active, done)
+			next, ok := x.Cdr.(*Pair)
+			if !ok {
+				p.scan(x.Cdr, active, done)
+				break
+			}
+			x = next
+		}
+		for _, c := range chain {
+			delete(active, c)
+			done[c] = true
+		}
+	case *Vector, *Hash:
+		if active[x] {
+			p.cycles[x] = true
+			return
+		}
+		if done[x] {
+			return
+		}
+		active[x] = true
+		if vec, ok := x.(*Vector); ok {
+			for _, i := range vec.Items {
+				p.scan(i, active, done)
+			}
+		} else {
+			h := x.(*Hash)
+			for i, k := range h.keys {
+				p.scan(k, active, done)
+				p.scan(h.vals[i], active, done)
+			}
+		}
+		delete(active, x)
+		done[x] = true
+	}
+}
+
+// label writes the label of v if it needs one, returning true if v has
+// been written already and only a reference to it was written.
+func (p *printer) label(v Value) bool {
+	if !p.cycles[v] {
+		return false
+	}
+	if n, ok := p.labels[v]; ok {
+		fmt.Fprintf(&p.b, "#%d#", n)
+		return true
+	}
+	n := len(p.labels)
+	p.labels[v] = n
+	fmt.Fprintf(&p.b, "#%d=", n)
+	return false
+}
+
+func (p *printer) value(v Value) {
+	b := &p.b
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("()")
+	case bool:
+		if v {
+			b.WriteString("#t")
+		} else {
+			b.WriteString("#f")
+		}
+	case float64:
+		writeFloat(b, v)
+	case string:
+		if p.display {
+			b.WriteString(v)
+		} else {
+			b.WriteString(strconv.Quote(v))
+		}
+	case Char:
+		if p.display {
+			b.WriteRune(rune(v))
+		} else {
+			writeChar(b, v)
+		}
+	case *Symbol:
+		b.WriteString(v.Name)
+	case *Pair:
+		if p.label(v) {
+			return
+		}
+		b.WriteByte('(')
+		for {
+			p.value(v.Car)
+			next, ok := v.Cdr.(*Pair)
+			if !ok || p.cycles[next] {
+				break
+			}
+			b.WriteByte(' ')
+			v = next
+		}
+		if v.Cdr != nil {
+			b.WriteString(" . ")
+			p.value(v.Cdr)
+		}
+		b.WriteByte(')')
+	case *Vector:
+		if p.label(v) {
+			return
+		}
+		b.WriteString("#(")
+		for i, x := range v.Items {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			p.value(x)
+		}
+		b.WriteByte(')')
+	case *Hash:
+		if p.label(v) {
+			return
+		}
+		b.WriteString("#hash(")
+		for i, k := range v.keys {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteByte('(')
+			p.value(k)
+			b.WriteString(" . ")
+			p.value(v.vals[i])
+			b.WriteByte(')')
+		}
+		b.WriteByte(')')
+	case *Builtin:
+		b.WriteString("#<builtin " + v.Name + ">")
+	case eofObject:
+		b.WriteString("#<eof>")
+	default:
+		fmt.Fprint(b, v)
+	}
+}
+
+// writeFloat writes f with a decimal point so that it reads back as a
+// float rather than an integer. Infinities and NaN have no literal form.
+func writeFloat(b *strings.Builder, f float64) {
+	switch {
+	case math.IsInf(f, 1):
+		b.WriteString("+inf.0")
+	case math.IsInf(f, -1):
+		b.WriteString("-inf.0")
+	case math.IsNaN(f):
+		b.WriteString("+nan.0")
+	default:
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		b.WriteString(s)
+		if !strings.Contains(s, ".") {
+			b.WriteString(".0")
+		}
+	}
+}
+
+// writeChar writes c as a literal that reads back as c.
+func writeChar(b *strings.Builder, c Char) {
+	b.WriteString("#\\")
+	for name, r := range charNames {
+		if rune(c) == r {
+			b.WriteString(name)
+			return
+		}
+	}
+	if unicode.IsGraphic(rune(c)) {
+		b.WriteRune(rune(c))
+	} else {
+		fmt.Fprintf(b, "x%x", c)
+	}
+}
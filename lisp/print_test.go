@@ -0,0 +1,139 @@
+package lisp
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+type printData struct {
+	test    string
+	written string // As printed by Write
+	printed string // As printed by Print
+}
+
+func TestPrint(t *testing.T) {
+	tests := []printData{
+		{`'("a\n\"b\"" #\c (d . "e"))`, `("a\n\"b\"" #\c (d . "e"))`, "(a\n\"b\" c (d . e))"},
+		{`#(#\space "s" #hash(("k" . 1.5)))`, `#(#\space "s" #hash(("k" . 1.5)))`, `#(  s #hash((k . 1.5)))`},
+		{`(list 6.0 -0.25 (/ 1 3) (* 1.0 1000000000 1000000000 100000))`, `(6.0 -0.25 0.3333333333333333 100000000000000000000000.0)`, ``},
+		{`(list "é\t" (integer->char 7) (integer->char 1))`, `("é\t" #\alarm #\x1)`, ""},
+		{`'(1 (2 (3 (4))) . 5)`, `(1 (2 (3 (4))) . 5)`, ""},
+		{`(define l (list 1 2 3)) (set-cdr! (cdr (cdr l)) l) l`, `#0=(1 2 3 . #0#)`, ""},
+		{`(define l (list 1 2)) (set-car! (cdr l) l) l`, `#0=(1 #0#)`, ""},
+		{`(define v (vector 1 2)) (vector-set! v 1 v) (list v v)`, `(#0=#(1 #0#) #0#)`, ""},
+		{`(define h (make-hash)) (hash-set! h 'self h) h`, `#0=#hash((self . #0#))`, ""},
+		{`(define a (list 1)) (list a a)`, `((1) (1))`, ""},
+		{`'#0=(a #1=(b . #1#) . #0#)`, `#0=(a #1=(b . #1#) . #0#)`, ""},
+		{`'#5=#(x #5#)`, `#0=#(x #0#)`, ""},
+	}
+	for _, tst := range tests {
+		in := New(&Options{Diagnostics: io.Discard, Stdout: io.Discard})
+		v, err := in.EvalString(tst.test)
+		if err != nil {
+			t.Errorf("For test string %s: %v", tst.test, err)
+			continue
+		}
+		var w, p strings.Builder
+		if err := Write(&w, v); err != nil {
+			t.Fatal(err)
+		}
+		if err := Print(&p, v); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != tst.written {
+			t.Errorf("For test string %s\nExpected written:\t%s\nGot:\t\t\t%s", tst.test, tst.written, w.String())
+		}
+		if tst.printed != "" && p.String() != tst.printed {
+			t.Errorf("For test string %s\nExpected printed:\t%q\nGot:\t\t\t%q", tst.test, tst.printed, p.String())
+		}
+		// The written form reads back as a value that writes the same
+		r, err := in.EvalString("'" + w.String())
+		if err != nil {
+			t.Errorf("Reading back %s: %v", w.String(), err)
+		} else if String(r) != w.String() {
+			t.Errorf("%s read back as %s", w.String(), String(r))
+		}
+	}
+}
+
+func TestWriteDisplay(t *testing.T) {
+	var out strings.Builder
+	in := New(&Options{Stdout: &out})
+	if _, err := in.EvalString(`(write "a" #\b 'c) (display "a" #\b 'c)`); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"a"#\bcabc`; out.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, out.String())
+	}
+}
+
+func TestDatumLabels(t *testing.T) {
+	tests := []evalData{
+		{`(let ((l '#0=(a . #0#))) (eq? l (cdr l)))`, "#t"},
+		{`'(#0=(x) #0#)`, "((x) (x))"},
+		{`(let ((l '(#0=(x) #0#))) (eq? (car l) (car (cdr l))))`, "#t"},
+		{`'#0#`, "1:2: Undefined datum label #0#"},
+		{`'(#0=a #0=b)`, "1:8: Duplicate datum label #0="},
+		{`'#0=#0#`, "1:5: Expecting datum after #0="},
+		{`#0=(+ 1 2)`, "1:4: Datum labels may only be used in quoted data"},
+		{`#1x`, "1:1: Invalid datum label [#1]"},
+	}
+	if err := runEvalTest(tests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrettyPrint(t *testing.T) {
+	tests := []struct {
+		test     string
+		width    int
+		expected string
+	}{
+		{`'(a b c)`, 80, `(a b c)`},
+		{`'(define (fact n) (if (< n 2) 1 (* n (fact (- n 1)))))`, 30, `(define (fact n)
+  (if (< n 2)
+      1
+      (* n (fact (- n 1)))))`},
+		{`'(lambda (x y) (display x) (display y))`, 20, `(lambda (x y)
+  (display x)
+  (display y))`},
+		{`'(let ((a 1) (b 2)) (+ a b))`, 14, `(let ((a 1)
+      (b 2))
+  (+ a b))`},
+		{`'((1 2 3) (4 5 6) . 7)`, 10, `((1 2 3)
+ (4 5 6)
+ . 7)`},
+		{`(vector "alpha" "beta" "gamma")`, 12, `#("alpha"
+  "beta"
+  "gamma")`},
+		{`(make-hash '((a . (1 2 3)) (b . 2)))`, 20, `#hash((a . (1 2 3))
+      (b . 2))`},
+		{`(make-hash '((a . ()) (b . (c d e f g h))))`, 16, `#hash((a . ())
+      (b . (c d
+              e
+              f
+              g
+              h)))`},
+		{`'(a-very-long-function-name argument-one argument-two)`, 40, `(a-very-long-function-name
+  argument-one
+  argument-two)`},
+		{`(define l (list 'alpha 'beta 'gamma)) (set-cdr! (cdr (cdr l)) l) l`, 10, `#0=(alpha
+     beta
+     gamma
+     . #0#)`},
+	}
+	for _, tst := range tests {
+		v, err := New(nil).EvalString(tst.test)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		if err := PrettyPrint(&b, v, tst.width); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tst.expected {
+			t.Errorf("For test string %s\nExpected:\n%s\nGot:\n%s", tst.test, tst.expected, b.String())
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package lisp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strings are Go strings holding UTF-8. Lengths and indices count runes,
+// not bytes.
+var stringBuiltins = []*Builtin{
+	{"string?", builtinIsString, 1, 1, "(string? x)\nReports whether x is a string."},
+	{"string-length", builtinStringLength, 1, 1, "(string-length s)\nReturns the number of runes in s."},
+	{"substring", builtinSubstring, 2, 3, "(substring s start [end])\nReturns the runes of s from start up to end, or to the end of s."},
+	{"string-append", builtinStringAppend, 0, -1, "(string-append s ...)\nReturns the strings joined together."},
+	{"string-split", builtinStringSplit, 1, 2, "(string-split s [sep])\nSplits s around each sep, or around runs of white space."},
+	{"string-join", builtinStringJoin, 1, 2, "(string-join list [sep])\nJoins a list of strings, separated by sep."},
+	{"string-index", builtinStringIndex, 2, 2, "(string-index s sub)\nReturns the rune index of the first sub in s, or #f."},
+	{"string-upcase", stringMap("string-upcase", strings.ToUpper), 1, 1, "(string-upcase s)\nReturns s in upper case."},
+	{"string-downcase", stringMap("string-downcase", strings.ToLower), 1, 1, "(string-downcase s)\nReturns s in lower case."},
+	{"string-trim", builtinStringTrim, 1, 2, "(string-trim s [cutset])\nRemoves the runes in cutset, or white space, from both ends of s."},
+	{"string-replace", builtinStringReplace, 3, 3, "(string-replace s old new)\nReplaces every old in s with new."},
+	{"string->number", builtinStringToNumber, 1, 1, "(string->number s)\nParses s as an integer or float, returning #f if it is neither."},
+	{"number->string", builtinNumberToString, 1, 1, "(number->string x)\nReturns x written as a string."},
+	{"string-ref", builtinStringRef, 2, 2, "(string-ref s i)\nReturns the i'th character of s."},
+	{"string-rune", builtinStringRune, 2, 2, "(string-rune s i)\nReturns the code point of the i'th rune of s."},
+	{"string->runes", builtinStringToRunes, 1, 1, "(string->runes s)\nReturns the code points of s as a list."},
+	{"runes->string", builtinRunesToString, 1, 1, "(runes->string list)\nReturns the string of a list of code points."},
+}
+
+// toStrings checks that args are all strings.
+func toStrings(name string, args []Value) ([]string, error) {
+	ss := make([]string, len(args))
+	for i, a := range args {
+		s, ok := a.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s expects a string, got %s", name, String(a))
+		}
+		ss[i] = s
+	}
+	return ss, nil
+}
+
+// toIndex checks that v is an integer between 0 and max inclusive.
+func toIndex(name string, v Value, max int) (int, error) {
+	i, ok := v.(int)
+	if !ok {
+		return 0, fmt.Errorf("%s expects an integer index, got %s", name, String(v))
+	}
+	if i < 0 || i > max {
+		return 0, fmt.Errorf("%s: index %d out of range", name, i)
+	}
+	return i, nil
+}
+
+func builtinIsString(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(string)
+	return ok, nil
+}
+
+func builtinStringLength(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-length", args, 1, 1); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-length", args)
+	if err != nil {
+		return nil, err
+	}
+	return utf8.RuneCountInString(ss[0]), nil
+}
+
+// (substring s start [end]) returns the runes of s from start up to end,
+// which defaults to the end of s.
+func builtinSubstring(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("substring", args, 2, 3); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("substring", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	rs := []rune(ss[0])
+	start, err := toIndex("substring", args[1], len(rs))
+	if err != nil {
+		return nil, err
+	}
+	end := len(rs)
+	if len(args) == 3 {
+		if end, err = toIndex("substring", args[2], len(rs)); err != nil {
+			return nil, err
+		}
+	}
+	if end < start {
+		return nil, fmt.Errorf("substring: end %d is before start %d", end, start)
+	}
+	return string(rs[start:end]), nil
+}
+
+func builtinStringAppend(in *Interpreter, args []Value) (Value, error) {
+	ss, err := toStrings("string-append", args)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Join(ss, ""), nil
+}
+
+// (string-split s [sep]) splits s around each sep, or around runs of white
+// space if sep is omitted.
+func builtinStringSplit(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-split", args, 1, 2); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-split", args)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	if len(ss) == 1 {
+		parts = strings.Fields(ss[0])
+	} else {
+		parts = strings.Split(ss[0], ss[1])
+	}
+	vs := make([]Value, len(parts))
+	for i, p := range parts {
+		vs[i] = p
+	}
+	return List(vs...), nil
+}
+
+// (string-join list [sep]) concatenates a list of strings, separated by
+// sep.
+func builtinStringJoin(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-join", args, 1, 2); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("string-join", args[0])
+	if err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-join", append(l, args[1:]...))
+	if err != nil {
+		return nil, err
+	}
+	sep := ""
+	if len(args) == 2 {
+		sep, ss = ss[len(ss)-1], ss[:len(ss)-1]
+	}
+	return strings.Join(ss, sep), nil
+}
+
+// (string-index s sub) returns the rune index of the first sub in s, or #f.
+func builtinStringIndex(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-index", args, 2, 2); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-index", args)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.Index(ss[0], ss[1])
+	if i < 0 {
+		return false, nil
+	}
+	return utf8.RuneCountInString(ss[0][:i]), nil
+}
+
+// stringMap returns a builtin applying f to its string argument.
+func stringMap(name string, f func(string) string) func(*Interpreter, []Value) (Value, error) {
+	return func(in *Interpreter, args []Value) (Value, error) {
+		if err := checkArgs(name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		ss, err := toStrings(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return f(ss[0]), nil
+	}
+}
+
+// (string-trim s [cutset]) removes the runes in cutset, or white space,
+// from both ends of s.
+func builtinStringTrim(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-trim", args, 1, 2); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-trim", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(ss) == 1 {
+		return strings.TrimSpace(ss[0]), nil
+	}
+	return strings.Trim(ss[0], ss[1]), nil
+}
+
+// (string-replace s old new) replaces every old in s with new.
+func builtinStringReplace(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-replace", args, 3, 3); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-replace", args)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(ss[0], ss[1], ss[2]), nil
+}
+
+// (string->number s) parses s as an integer or float, returning #f if it is
+// neither.
+func builtinStringToNumber(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string->number", args, 1, 1); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string->number", args)
+	if err != nil {
+		return nil, err
+	}
+	if i, err := strconv.Atoi(ss[0]); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(ss[0], 64); err == nil {
+		return f, nil
+	}
+	return false, nil
+}
+
+func builtinNumberToString(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("number->string", args, 1, 1); err != nil {
+		return nil, err
+	}
+	if err := checkNumbers("number->string", args); err != nil {
+		return nil, err
+	}
+	return String(args[0]), nil
+}
+
+// (string-ref s i) returns the i'th character of s.
+func builtinStringRef(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-ref", args, 2, 2); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-ref", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	rs := []rune(ss[0])
+	i, err := toIndex("string-ref", args[1], len(rs)-1)
+	if err != nil {
+		return nil, err
+	}
+	return Char(rs[i]), nil
+}
+
+// (string-rune s i) returns the code point of the i'th rune of s.
+func builtinStringRune(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string-rune", args, 2, 2); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string-rune", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	rs := []rune(ss[0])
+	i, err := toIndex("string-rune", args[1], len(rs)-1)
+	if err != nil {
+		return nil, err
+	}
+	return int(rs[i]), nil
+}
+
+// (string->runes s) returns the code points of s as a list.
+func builtinStringToRunes(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("string->runes", args, 1, 1); err != nil {
+		return nil, err
+	}
+	ss, err := toStrings("string->runes", args)
+	if err != nil {
+		return nil, err
+	}
+	var vs []Value
+	for _, r := range ss[0] {
+		vs = append(vs, int(r))
+	}
+	return List(vs...), nil
+}
+
+// (runes->string list) returns the string of a list of code points.
+func builtinRunesToString(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("runes->string", args, 1, 1); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("runes->string", args[0])
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, v := range l {
+		r, ok := v.(int)
+		if !ok || !isCodePoint(r) {
+			return nil, fmt.Errorf("runes->string expects code points, got %s", String(v))
+		}
+		b.WriteRune(rune(r))
+	}
+	return b.String(), nil
+}
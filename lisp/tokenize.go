@@ -0,0 +1,139 @@
+package lisp
+
+import (
+	"bytes"
+	"io"
+)
+
+// A TokenKind classifies a Token for syntax highlighting.
+type TokenKind int
+
+const (
+	SpaceToken       TokenKind = iota
+	CommentToken               // Including #; and the datum it comments out
+	ParenToken                 // ( ) #( and #hash(
+	PunctuationToken           // ' . and datum labels
+	KeywordToken               // The name of a special form
+	BuiltinToken               // The name of a builtin or constant
+	SymbolToken
+	StringToken
+	NumberToken
+	CharToken
+	BoolToken
+	ErrorToken
+)
+
+var tokenKindNames = [...]string{"space", "comment", "paren", "punctuation", "keyword", "builtin", "symbol", "string", "number", "char", "bool", "error"}
+
+// String returns the lower case name of k, such as "keyword".
+func (k TokenKind) String() string {
+	if k < 0 || int(k) >= len(tokenKindNames) {
+		return "unknown"
+	}
+	return tokenKindNames[k]
+}
+
+// A Token is a piece of source as returned by Tokenize.
+type Token struct {
+	Kind TokenKind
+	// Offset and Len give the bytes of the source the token covers
+	Offset, Len int
+	Row, Col    int
+	// Depth is the nesting depth of a ParenToken, 0 for a list at the top
+	// level, the same for its opening and closing parentheses
+	Depth int
+}
+
+// Tokenize returns the tokens of src, including its white space, in
+// order. Together they cover every byte of src. Symbols are classified by
+// name alone, so a variable that shadows a builtin is still a BuiltinToken.
+// A ')' that closes nothing is an ErrorToken, as are the malformed tokens
+// evaluation would report.
+func Tokenize(src []byte) []Token {
+	l := newLexer(bytes.NewReader(src), &Options{Diagnostics: io.Discard})
+	l.spaces = true
+	var (
+		toks  []Token
+		depth int
+		// skip counts the datums still to be commented out by #;, and
+		// start is the depth the one being commented out began at, or -1
+		skip  int
+		start = -1
+	)
+	builtin := map[string]bool{}
+	for name := range constants {
+		builtin[name] = true
+	}
+	for _, bs := range builtinTables {
+		for _, b := range bs {
+			builtin[b.Name] = true
+		}
+	}
+	for t := l.next(); t.typ != tokenEOF; t = l.next() {
+		tok := Token{Offset: t.off, Len: t.end - t.off, Row: t.row, Col: t.col}
+		switch t.typ {
+		case tokenSpace:
+			tok.Kind = SpaceToken
+		case tokenComment:
+			tok.Kind = CommentToken
+		case tokenDatumComment:
+			tok.Kind = CommentToken
+			if start < 0 {
+				skip++
+			}
+		case tokenLParen, tokenVector, tokenHash:
+			tok.Kind, tok.Depth = ParenToken, depth
+			depth++
+		case tokenRParen:
+			if depth == 0 {
+				tok.Kind = ErrorToken
+				break
+			}
+			depth--
+			tok.Kind, tok.Depth = ParenToken, depth
+		case tokenQuote, tokenDot, tokenLabel, tokenLabelRef:
+			tok.Kind = PunctuationToken
+		case tokenAtom:
+			name := t.val.(*Symbol).Name
+			switch {
+			case specialForm(name):
+				tok.Kind = KeywordToken
+			case builtin[name]:
+				tok.Kind = BuiltinToken
+			default:
+				tok.Kind = SymbolToken
+			}
+		case tokenString:
+			tok.Kind = StringToken
+		case tokenNumber:
+			tok.Kind = NumberToken
+		case tokenChar:
+			tok.Kind = CharToken
+		case tokenBool:
+			tok.Kind = BoolToken
+		default:
+			tok.Kind = ErrorToken
+		}
+		if tok.Kind != SpaceToken && tok.Kind != CommentToken && (start >= 0 || skip > 0) {
+			opens := t.typ == tokenLParen || t.typ == tokenVector || t.typ == tokenHash
+			prefix := t.typ == tokenQuote || t.typ == tokenLabel || t.typ == tokenDot
+			if start < 0 && t.typ == tokenRParen {
+				// The list ended before the datum to comment out
+				skip = 0
+			} else {
+				if start < 0 {
+					start = depth
+					if opens {
+						start--
+					}
+				}
+				tok.Kind = CommentToken
+				if depth == start && !opens && !prefix {
+					start, skip = -1, skip-1
+				}
+			}
+		}
+		toks = append(toks, tok)
+	}
+	return toks
+}
@@ -0,0 +1,54 @@
+package lisp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		test     string
+		expected string
+	}{
+		{"(define (f x) (car x))", "paren0:( keyword:define space:  paren1:( symbol:f space:  symbol:x paren1:) space:  paren1:( builtin:car space:  symbol:x paren1:) paren0:)"},
+		{"; héllo\n'(1 \"s\" #\\a #t . 2.5)", "comment:; héllo space:\n punctuation:' paren0:( number:1 space:  string:\"s\" space:  char:#\\a space:  bool:#t space:  punctuation:. space:  number:2.5 paren0:)"},
+		{"#;(a (b)) c #| x |#", "comment:#; comment:( comment:a space:  comment:( comment:b comment:) comment:) space:  symbol:c space:  comment:#| x |#"},
+		{"(#; #; a 'b c)", "paren0:( comment:#; space:  comment:#; space:  comment:a space:  comment:' comment:b space:  symbol:c paren0:)"},
+		{"(#;) x)", "paren0:( comment:#; paren0:) space:  symbol:x error:)"},
+		{"#hash((k . #0=v)) \"open", "paren0:#hash( paren1:( symbol:k space:  punctuation:. space:  punctuation:#0= symbol:v paren1:) paren0:) space:  error:\"open"},
+		{"  \t\n", "space:  \t\n"},
+		{"#!/usr/bin/env lisp\nx -", "comment:#!/usr/bin/env lisp space:\n symbol:x space:  builtin:-"},
+	}
+	for _, tst := range tests {
+		var got []string
+		var all strings.Builder
+		for _, tok := range Tokenize([]byte(tst.test)) {
+			text := tst.test[tok.Offset : tok.Offset+tok.Len]
+			all.WriteString(text)
+			if tok.Kind == ParenToken {
+				got = append(got, fmt.Sprintf("%s%d:%s", tok.Kind, tok.Depth, text))
+			} else {
+				got = append(got, fmt.Sprintf("%s:%s", tok.Kind, text))
+			}
+		}
+		if s := strings.Join(got, " "); s != tst.expected {
+			t.Errorf("For test string %q\nExpected:\t%s\nGot:\t\t%s", tst.test, tst.expected, s)
+		}
+		if all.String() != tst.test {
+			t.Errorf("The tokens of %q cover %q", tst.test, all.String())
+		}
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	toks := Tokenize([]byte("(a\n  \"é\" b)"))
+	var got []string
+	for _, tok := range toks {
+		got = append(got, fmt.Sprintf("%d:%d@%d+%d", tok.Row, tok.Col, tok.Offset, tok.Len))
+	}
+	expected := "1:1@0+1 1:2@1+1 1:3@2+3 2:3@5+4 2:6@9+1 2:7@10+1 2:8@11+1"
+	if s := strings.Join(got, " "); s != expected {
+		t.Errorf("Expected:\t%s\nGot:\t\t%s", expected, s)
+	}
+}
@@ -16,11 +16,21 @@ func _() {
 	_ = x[tokenQuote-5]
 	_ = x[tokenAtom-6]
 	_ = x[tokenNumber-7]
+	_ = x[tokenDatumComment-8]
+	_ = x[tokenDot-9]
+	_ = x[tokenString-10]
+	_ = x[tokenChar-11]
+	_ = x[tokenVector-12]
+	_ = x[tokenHash-13]
+	_ = x[tokenBool-14]
+	_ = x[tokenLabel-15]
+	_ = x[tokenLabelRef-16]
+	_ = x[tokenSpace-17]
 }
 
-const _tokenTyp_name = "tokenErrortokenEOFtokenCommenttokenLParentokenRParentokenQuotetokenAtomtokenNumber"
+const _tokenTyp_name = "tokenErrortokenEOFtokenCommenttokenLParentokenRParentokenQuotetokenAtomtokenNumbertokenDatumCommenttokenDottokenStringtokenChartokenVectortokenHashtokenBooltokenLabeltokenLabelReftokenSpace"
 
-var _tokenTyp_index = [...]uint8{0, 10, 18, 30, 41, 52, 62, 71, 82}
+var _tokenTyp_index = [...]uint8{0, 10, 18, 30, 41, 52, 62, 71, 82, 99, 107, 118, 127, 138, 147, 156, 166, 179, 189}
 
 func (i tokenTyp) String() string {
 	if i < 0 || i >= tokenTyp(len(_tokenTyp_index)-1) {
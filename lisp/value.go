@@ -0,0 +1,211 @@
+package lisp
+
+import "sync"
+
+// Value is any Lisp value. Numbers are int or float64, strings are string,
+// booleans are bool and the empty list is nil. Everything else is one of
+// the types below, such as Char.
+type Value interface{}
+
+// Char is a character, holding one Unicode code point.
+type Char rune
+
+// Symbol is an interned name. There is only ever one *Symbol for a given
+// name, so symbols compare with ==.
+type Symbol struct {
+	Name string
+}
+
+func (s *Symbol) String() string {
+	return s.Name
+}
+
+// symbols is the table of every symbol interned by the process. Symbols
+// are shared between interpreters and never released.
+var symbols = struct {
+	sync.Mutex
+	m map[string]*Symbol
+}{m: map[string]*Symbol{}}
+
+// Intern returns the symbol named name, creating it if necessary.
+func Intern(name string) *Symbol {
+	symbols.Lock()
+	defer symbols.Unlock()
+	s, ok := symbols.m[name]
+	if !ok {
+		s = &Symbol{name}
+		symbols.m[name] = s
+	}
+	return s
+}
+
+// EOF is the value read and read-line return at the end of standard
+// input.
+var EOF Value = eofObject{}
+
+type eofObject struct{}
+
+// Pair is a cons cell. A list is a chain of pairs linked by their Cdr
+// and ending in nil, the empty list.
+type Pair struct {
+	Car, Cdr Value
+}
+
+// Vector is a fixed length array of values.
+type Vector struct {
+	Items []Value
+}
+
+// Hash is a mutable hash table. Keys compare as with eq?, so numbers,
+// strings, symbols and characters match by value and other values by
+// identity. Keys are kept in the order they were first added.
+type Hash struct {
+	index map[Value]int
+	keys  []Value
+	vals  []Value
+}
+
+// Builtin is a procedure implemented in Go.
+type Builtin struct {
+	Name string
+	Fn   func(in *Interpreter, args []Value) (Value, error)
+	// Min and Max are the numbers of arguments Fn accepts, where a Max of
+	// -1 means any number. Vet checks calls against them.
+	Min, Max int
+	// Doc shows how the procedure is called on its first line, followed
+	// by what it does
+	Doc string
+}
+
+// Lambda is a procedure defined in Lisp, evaluated by the tree walker.
+type Lambda struct {
+	node *lambdaNode
+	env  *frame
+	name string
+}
+
+// A frame holds the local variables of one scope, in the order of the
+// scope's names.
+type frame struct {
+	vals  []Value
+	names []*Symbol
+	up    *frame
+}
+
+// unset is the value of a local variable whose definition has not been
+// evaluated yet.
+type unset struct{}
+
+// List returns a proper list of vs.
+func List(vs ...Value) Value {
+	var l Value
+	for i := len(vs) - 1; i >= 0; i-- {
+		l = &Pair{vs[i], l}
+	}
+	return l
+}
+
+// truthy reports whether v counts as true in a test. Only false and the
+// empty list are false.
+func truthy(v Value) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return v != nil
+}
+
+// equal reports whether a and b are structurally the same. Numbers compare
+// by value regardless of representation. Values that contain themselves
+// are equal if no difference can be found between them.
+func equal(a, b Value) bool {
+	var q equality
+	return q.equal(a, b)
+}
+
+// equality compares values that may contain themselves. Meeting two
+// containers again while comparing them, it takes them to be equal, so
+// that whether they are is decided by the rest of the comparison.
+type equality struct {
+	seen map[[2]Value]bool
+}
+
+// visit reports whether x and y have been met before, recording them.
+func (q *equality) visit(x, y Value) bool {
+	if q.seen == nil {
+		q.seen = map[[2]Value]bool{}
+	}
+	k := [2]Value{x, y}
+	if q.seen[k] {
+		return true
+	}
+	q.seen[k] = true
+	return false
+}
+
+func (q *equality) equal(a, b Value) bool {
+	switch x := a.(type) {
+	case int, float64:
+		if !isNumber(b) {
+			return false
+		}
+		c, _ := compare(x, b)
+		return c == 0
+	case *Pair:
+		// Follow the cdrs in a loop so long lists do not nest
+		for {
+			y, ok := b.(*Pair)
+			if !ok {
+				return false
+			}
+			if x == y || q.visit(x, y) {
+				return true
+			}
+			if !q.equal(x.Car, y.Car) {
+				return false
+			}
+			next, ok := x.Cdr.(*Pair)
+			if !ok {
+				return q.equal(x.Cdr, y.Cdr)
+			}
+			x, b = next, y.Cdr
+		}
+	case *Vector:
+		y, ok := b.(*Vector)
+		if !ok || len(x.Items) != len(y.Items) {
+			return false
+		}
+		if x == y || q.visit(x, y) {
+			return true
+		}
+		for i := range x.Items {
+			if !q.equal(x.Items[i], y.Items[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		y, ok := b.(*Hash)
+		if !ok || x.Len() != y.Len() {
+			return false
+		}
+		if x == y || q.visit(x, y) {
+			return true
+		}
+		for i, k := range x.keys {
+			v, ok := y.Get(k)
+			if !ok || !q.equal(x.vals[i], v) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func isNumber(v Value) bool {
+	switch v.(type) {
+	case int, float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package lisp
+
+import "fmt"
+
+var vectorBuiltins = []*Builtin{
+	{"vector", builtinVector, 0, -1, "(vector x ...)\nReturns a vector of its arguments."},
+	{"make-vector", builtinMakeVector, 1, 2, "(make-vector n [fill])\nReturns a vector of n fills, which default to ()."},
+	{"vector?", builtinIsVector, 1, 1, "(vector? x)\nReports whether x is a vector."},
+	{"vector-length", builtinVectorLength, 1, 1, "(vector-length v)\nReturns the number of elements of v."},
+	{"vector-ref", builtinVectorRef, 2, 2, "(vector-ref v i)\nReturns the i'th element of v."},
+	{"vector-set!", builtinVectorSet, 3, 3, "(vector-set! v i x)\nSets the i'th element of v to x."},
+	{"vector->list", builtinVectorToList, 1, 1, "(vector->list v)\nReturns the elements of v as a list."},
+	{"list->vector", builtinListToVector, 1, 1, "(list->vector list)\nReturns the elements of list as a vector."},
+}
+
+func toVector(name string, v Value) (*Vector, error) {
+	vec, ok := v.(*Vector)
+	if !ok {
+		return nil, fmt.Errorf("%s expects a vector, got %s", name, String(v))
+	}
+	return vec, nil
+}
+
+func builtinVector(in *Interpreter, args []Value) (Value, error) {
+	return &Vector{append([]Value(nil), args...)}, nil
+}
+
+// (make-vector n [fill]) returns a vector of n fills, which default to ().
+func builtinMakeVector(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("make-vector", args, 1, 2); err != nil {
+		return nil, err
+	}
+	n, ok := args[0].(int)
+	if !ok || n < 0 {
+		return nil, fmt.Errorf("make-vector expects a length, got %s", String(args[0]))
+	}
+	vs := make([]Value, n)
+	if len(args) == 2 {
+		for i := range vs {
+			vs[i] = args[1]
+		}
+	}
+	return &Vector{vs}, nil
+}
+
+func builtinIsVector(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("vector?", args, 1, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(*Vector)
+	return ok, nil
+}
+
+func builtinVectorLength(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("vector-length", args, 1, 1); err != nil {
+		return nil, err
+	}
+	v, err := toVector("vector-length", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return len(v.Items), nil
+}
+
+func builtinVectorRef(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("vector-ref", args, 2, 2); err != nil {
+		return nil, err
+	}
+	v, err := toVector("vector-ref", args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := toIndex("vector-ref", args[1], len(v.Items)-1)
+	if err != nil {
+		return nil, err
+	}
+	return v.Items[i], nil
+}
+
+func builtinVectorSet(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("vector-set!", args, 3, 3); err != nil {
+		return nil, err
+	}
+	v, err := toVector("vector-set!", args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := toIndex("vector-set!", args[1], len(v.Items)-1)
+	if err != nil {
+		return nil, err
+	}
+	v.Items[i] = args[2]
+	return args[2], nil
+}
+
+func builtinVectorToList(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("vector->list", args, 1, 1); err != nil {
+		return nil, err
+	}
+	v, err := toVector("vector->list", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return List(v.Items...), nil
+}
+
+func builtinListToVector(in *Interpreter, args []Value) (Value, error) {
+	if err := checkArgs("list->vector", args, 1, 1); err != nil {
+		return nil, err
+	}
+	l, err := toSlice("list->vector", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Vector{l}, nil
+}
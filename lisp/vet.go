@@ -0,0 +1,528 @@
+package lisp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Check reports the errors evaluating src would report before running
+// anything: unbalanced parentheses, and lexical, syntax and analysis
+// errors such as unbound variables. The errors are in source order.
+func Check(src []byte) ErrorList {
+	_, errs, _ := check(src)
+	return errs
+}
+
+// check parses and analyzes src, returning its expressions unless it could
+// not be parsed, the errors found, and the globals procedures refer to
+// that are never bound.
+func check(src []byte) ([]*expr, ErrorList, ErrorList) {
+	if errs := checkParens(src); errs != nil {
+		return nil, errs, nil
+	}
+	opts := &Options{Diagnostics: io.Discard}
+	p := newParser(newLexer(bytes.NewReader(src), opts))
+	var es []*expr
+	for {
+		e, err := p.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if pe, ok := err.(*parseError); ok {
+				return nil, ErrorList{{Row: pe.row, Col: pe.col, Msg: pe.msg}}, nil
+			}
+			return nil, ErrorList{{Msg: err.Error()}}, nil
+		}
+		es = append(es, e)
+	}
+	in := &Interpreter{opts: opts, global: standard().global}
+	_, errs, unbound := in.analyzeProgram(es)
+	return es, sortErrors(errs), unbound
+}
+
+// Vet reports likely mistakes in the program src without evaluating it:
+// the errors Check reports, globals procedures refer to that are never
+// bound, let bindings and local definitions that are
+// never used, bindings that shadow builtins, calls with the wrong number
+// of arguments to builtins and to procedures that are never reassigned,
+// switch clauses that can never match, and code after calls that never
+// return, such as calls to error. The problems are in source order, and
+// all but those Check reports are marked as warnings.
+func Vet(src []byte) ErrorList {
+	es, errs, unbound := check(src)
+	if es == nil {
+		return errs
+	}
+	for _, e := range unbound {
+		e.Warning = true
+	}
+	v := newVetter()
+	v.program(es)
+	return sortErrors(append(append(errs, unbound...), v.errs...))
+}
+
+func sortErrors(errs ErrorList) ErrorList {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Row != errs[j].Row {
+			return errs[i].Row < errs[j].Row
+		}
+		return errs[i].Col < errs[j].Col
+	})
+	return errs
+}
+
+// checkParens reports the parentheses in src that are not matched,
+// positioned at the parentheses themselves, and lexical errors.
+func checkParens(src []byte) ErrorList {
+	l := newLexer(bytes.NewReader(src), &Options{Diagnostics: io.Discard})
+	var (
+		open []*token
+		errs ErrorList
+	)
+	for {
+		t := l.next()
+		switch t.typ {
+		case tokenError:
+			return append(errs, &Error{Row: t.row, Col: t.col, Msg: t.err})
+		case tokenLParen, tokenVector, tokenHash:
+			open = append(open, t)
+		case tokenRParen:
+			if len(open) == 0 {
+				errs = append(errs, &Error{Row: t.row, Col: t.col, Msg: "Unexpected ')'"})
+			} else {
+				open = open[:len(open)-1]
+			}
+		case tokenEOF:
+			for _, o := range open {
+				errs = append(errs, &Error{Row: o.row, Col: o.col, Msg: fmt.Sprintf("Unclosed '%s'", o.raw)})
+			}
+			return errs
+		}
+	}
+}
+
+// A binding is a variable as the vetter sees it.
+type binding struct {
+	e     *expr // The name where it is bound
+	local bool  // A let binding or local definition, reported if unused
+	used  bool
+	// proc is set for variables bound to procedures and never assigned,
+	// with the number of arguments they take
+	proc     bool
+	min, max int
+	sig      string // How a procedure is called, as in (f x y)
+	doc      string // The docstring of a procedure
+	noReturn bool   // A procedure that always ends by raising an error
+}
+
+type vetScope struct {
+	vars map[*Symbol]*binding
+	up   *vetScope
+}
+
+type vetter struct {
+	scope    *vetScope
+	builtins map[*Symbol]bool // The builtins and constants
+	assigned map[*Symbol]bool // Names assigned by set! anywhere
+	errs     ErrorList
+	// refs, if not nil, records the binding of each name bound or referred
+	// to. The variables visible at the atom point are kept in visible,
+	// innermost first.
+	refs    map[*expr]*binding
+	point   *expr
+	visible [][]*binding
+}
+
+func newVetter() *vetter {
+	v := &vetter{scope: &vetScope{vars: map[*Symbol]*binding{}}, builtins: map[*Symbol]bool{}, assigned: map[*Symbol]bool{}}
+	for name := range constants {
+		v.builtins[Intern(name)] = true
+	}
+	for _, bs := range builtinTables {
+		for _, b := range bs {
+			v.builtins[Intern(b.Name)] = true
+		}
+	}
+	return v
+}
+
+func (v *vetter) errorf(e *expr, format string, args ...interface{}) {
+	err := errorf(e, format, args...)
+	err.Warning = true
+	v.errs = append(v.errs, err)
+}
+
+// lookup returns the binding of name, or nil if it is a builtin or
+// unbound.
+func (v *vetter) lookup(name *Symbol) *binding {
+	for s := v.scope; s != nil; s = s.up {
+		if b, ok := s.vars[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// bind adds the variable named by e to the current scope.
+func (v *vetter) bind(e *expr, local bool) *binding {
+	name := e.sym()
+	if name == nil {
+		return nil
+	}
+	if v.builtins[name] {
+		v.errorf(e, "%s shadows a builtin", name)
+	}
+	b := &binding{e: e, local: local}
+	v.scope.vars[name] = b
+	if v.refs != nil {
+		v.refs[e] = b
+	}
+	return b
+}
+
+func (v *vetter) push() {
+	v.scope = &vetScope{vars: map[*Symbol]*binding{}, up: v.scope}
+}
+
+// pop leaves the current scope, reporting its unused variables.
+func (v *vetter) pop() {
+	for name, b := range v.scope.vars {
+		if b.local && !b.used {
+			v.errorf(b.e, "%s is bound but never used", name)
+		}
+	}
+	v.scope = v.scope.up
+}
+
+// program vets the top level expressions es.
+func (v *vetter) program(es []*expr) {
+	for _, e := range es {
+		v.findAssigned(e)
+	}
+	defs := v.declare(es, false)
+	// A procedure never returns if its body ends with a call to one that
+	// never returns, so repeat until no more are found
+	for changed := true; changed; {
+		changed = false
+		for b, body := range defs {
+			if !b.noReturn && len(body) > 0 && v.noReturn(body[len(body)-1]) {
+				b.noReturn, changed = true, true
+			}
+		}
+	}
+	v.body(es)
+}
+
+// findAssigned records the names assigned by set! in e.
+func (v *vetter) findAssigned(e *expr) {
+	if !e.isList() || e.lit != 0 || e.first == nil || e.first.symbol() == "quote" {
+		return
+	}
+	items := e.items()
+	if e.first.symbol() == "set!" && len(items) > 1 && items[1].sym() != nil {
+		v.assigned[items[1].sym()] = true
+	}
+	for _, x := range items {
+		v.findAssigned(x)
+	}
+}
+
+// declare binds the names defined by body in the current scope, returning
+// the bodies of the procedures among them.
+func (v *vetter) declare(body []*expr, local bool) map[*binding][]*expr {
+	defs := map[*binding][]*expr{}
+	for _, e := range body {
+		if !e.isList() || e.first == nil || e.lit != 0 {
+			continue
+		}
+		args := e.items()[1:]
+		switch e.first.symbol() {
+		case "define":
+			if len(args) < 2 {
+				continue
+			}
+			if sig := args[0]; sig.isList() && sig.first != nil {
+				params := sig.rest
+				if params == nil {
+					params = sig.tail
+				}
+				if b := v.bind(sig.first, local); b != nil {
+					v.procedure(b, params)
+					defs[b], b.doc = args[1:], docstring(args[1:])
+				}
+			} else if b := v.bind(args[0], local); b != nil {
+				if l := args[1]; l.isList() && l.first != nil && l.first.symbol() == "lambda" && len(l.items()) > 2 {
+					v.procedure(b, l.items()[1])
+					defs[b], b.doc = l.items()[2:], docstring(l.items()[2:])
+				}
+			}
+		case "do":
+			for b, body := range v.declare(args, local) {
+				defs[b] = body
+			}
+		}
+	}
+	return defs
+}
+
+// procedure records that b is a procedure taking params, unless b is
+// assigned elsewhere.
+func (v *vetter) procedure(b *binding, params *expr) {
+	b.sig = signature(b.e.symbol(), params)
+	if v.assigned[b.e.sym()] {
+		return
+	}
+	b.proc = true
+	if params == nil {
+		return
+	}
+	if !params.isList() {
+		b.max = -1
+		return
+	}
+	b.min = len(params.items())
+	b.max = b.min
+	if params.dotted() != nil {
+		b.max = -1
+	}
+}
+
+// noReturn reports whether e is a call to a procedure that never returns.
+func (v *vetter) noReturn(e *expr) bool {
+	if !e.isList() || e.lit != 0 || e.first == nil || e.first.sym() == nil {
+		return false
+	}
+	name := e.first.sym()
+	if b := v.lookup(name); b != nil {
+		return b.noReturn
+	}
+	return name.Name == "error"
+}
+
+// body vets a sequence of expressions, whose definitions have been
+// declared.
+func (v *vetter) body(es []*expr) {
+	for i, e := range es {
+		v.expr(e)
+		if i < len(es)-1 && v.noReturn(e) {
+			v.errorf(es[i+1], "Unreachable code")
+			for _, e := range es[i+1:] {
+				v.expr(e)
+			}
+			return
+		}
+	}
+}
+
+func (v *vetter) expr(e *expr) {
+	if !e.isList() {
+		if name := e.sym(); name != nil {
+			if b := v.lookup(name); b != nil {
+				b.used = true
+				if v.refs != nil {
+					v.refs[e] = b
+				}
+			}
+		}
+		if e == v.point {
+			for s := v.scope; s != nil; s = s.up {
+				var bs []*binding
+				for _, b := range s.vars {
+					bs = append(bs, b)
+				}
+				v.visible = append(v.visible, bs)
+			}
+		}
+		return
+	}
+	if e.lit != 0 || e.first == nil || e.dotted() != nil {
+		return
+	}
+	args := e.items()[1:]
+	switch e.first.symbol() {
+	case "quote", "module", "import":
+		return
+	case "if", "and", "or":
+		for _, a := range args {
+			v.expr(a)
+		}
+		return
+	case "do":
+		v.body(args)
+		return
+	case "switch":
+		v.vetSwitch(args)
+		return
+	case "define":
+		if len(args) < 2 {
+			return
+		}
+		if sig := args[0]; sig.isList() {
+			params := sig.rest
+			if params == nil {
+				params = sig.tail
+			}
+			v.lambda(params, args[1:])
+			return
+		}
+		v.expr(args[1])
+		return
+	case "set!":
+		if len(args) > 0 && v.refs != nil && args[0].sym() != nil {
+			// Assigning to a variable is not using it
+			if b := v.lookup(args[0].sym()); b != nil {
+				v.refs[args[0]] = b
+			}
+		}
+		for _, a := range args[1:] {
+			v.expr(a)
+		}
+		return
+	case "lambda":
+		if len(args) > 0 {
+			v.lambda(args[0], args[1:])
+		}
+		return
+	case "let":
+		v.let(args)
+		return
+	}
+	for _, a := range e.items() {
+		v.expr(a)
+	}
+	name := e.first.sym()
+	if name == nil {
+		return
+	}
+	if b := v.lookup(name); b != nil {
+		if b.proc {
+			v.arity(e, name, len(args), b.min, b.max)
+		}
+	} else if b, ok := builtinIndex[name.Name]; ok && v.builtins[name] {
+		v.arity(e, name, len(args), b.Min, b.Max)
+	}
+}
+
+func (v *vetter) arity(e *expr, name *Symbol, n, min, max int) {
+	if err := checkArity(name.Name, n, min, max); err != nil {
+		v.errorf(e, "%s", err)
+	}
+}
+
+func (v *vetter) lambda(params *expr, body []*expr) {
+	v.push()
+	if params != nil && !params.isList() {
+		v.bind(params, false)
+	} else if params != nil {
+		for _, p := range params.items() {
+			v.bind(p, false)
+		}
+		if r := params.dotted(); r != nil {
+			v.bind(r, false)
+		}
+	}
+	v.declare(body, true)
+	v.body(body)
+	v.pop()
+}
+
+func (v *vetter) let(args []*expr) {
+	if len(args) < 1 || !args[0].isList() {
+		return
+	}
+	var bindings [][]*expr
+	for _, b := range args[0].items() {
+		if kv := b.items(); b.isList() && len(kv) == 2 {
+			v.expr(kv[1])
+			bindings = append(bindings, kv)
+		}
+	}
+	v.push()
+	for _, kv := range bindings {
+		b := v.bind(kv[0], true)
+		if l := kv[1]; b != nil && l.isList() && l.first != nil && l.first.symbol() == "lambda" && len(l.items()) > 2 {
+			v.procedure(b, l.items()[1])
+		}
+	}
+	v.declare(args[1:], true)
+	v.body(args[1:])
+	v.pop()
+}
+
+// constant returns the value of e if it is a literal.
+func constant(e *expr) (Value, bool) {
+	switch {
+	case !e.isList():
+		if e.atom.typ == tokenAtom || e.atom.typ == tokenLabelRef {
+			return nil, false
+		}
+		return e.atom.val, true
+	case e.lit != 0 || e.first == nil:
+		return quote(e), true
+	case e.first.symbol() == "quote" && len(e.items()) == 2:
+		return quote(e.items()[1]), true
+	}
+	return nil, false
+}
+
+// vetSwitch reports the clauses of a switch that can never match: those
+// whose match is a constant that an earlier match or a constant key rules
+// out, and in a switch without a key, those after a test that is always
+// true or with a test that is always false.
+func (v *vetter) vetSwitch(args []*expr) {
+	for _, a := range args {
+		v.expr(a)
+	}
+	if len(args) == 0 || !args[0].isList() || len(args[0].items()) > 1 {
+		return
+	}
+	keyed := args[0].first != nil
+	var (
+		key     Value
+		knowKey bool
+		seen    []Value
+	)
+	if keyed {
+		key, knowKey = constant(args[0].first)
+	}
+	clauses := args[1:]
+	for ; len(clauses) >= 2; clauses = clauses[2:] {
+		c, ok := constant(clauses[0])
+		if !ok {
+			continue
+		}
+		if !keyed {
+			if !truthy(c) {
+				v.errorf(clauses[0], "Switch clause %s can never match", String(c))
+				continue
+			}
+			// Every clause after an always true test is unreachable
+			if len(clauses) > 2 {
+				v.errorf(clauses[2], "Unreachable code")
+			}
+			return
+		}
+		never := knowKey && !equal(key, c)
+		for _, s := range seen {
+			never = never || equal(s, c)
+		}
+		if never {
+			v.errorf(clauses[0], "Switch clause %s can never match", String(c))
+		}
+		seen = append(seen, c)
+	}
+}
+
+// signature returns how the procedure name taking params is called.
+func signature(name string, params *expr) string {
+	switch {
+	case params == nil || params.isList() && params.first == nil:
+		return "(" + name + ")"
+	case !params.isList():
+		return "(" + name + " . " + params.symbol() + ")"
+	}
+	return "(" + name + " " + String(quote(params))[1:]
+}
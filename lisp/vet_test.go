@@ -0,0 +1,148 @@
+package lisp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestVet(t *testing.T) {
+	tests := []struct {
+		test     string
+		expected string // The problems, one per line
+	}{
+		{`(define (f x) (+ x 1)) (f 2)`, ""},
+		{"(a (b\n(c))) )\n(d", "2:7: Unexpected ')'\n3:1: Unclosed '('"},
+		{`(display "a)`, `1:10: Unterminated string`},
+		{`(let ((a 1) (b 2)) b)`, "1:8: a is bound but never used"},
+		{`(lambda (x) (define (h) 1) x)`, "1:22: h is bound but never used"},
+		{`(lambda (x y) x)`, ""},
+		{`(define (g list) list)`, "1:12: list shadows a builtin"},
+		{`(define car 1)`, "1:9: car shadows a builtin"},
+		{`(car 1 2)`, "1:1: car expects 1 arguments, got 2"},
+		{`(define (f x . r) x) (f)`, "1:22: f expects at least 1 arguments, got 0"},
+		{`(let ((k (lambda (x) x))) (k 1 2))`, "1:27: k expects 1 arguments, got 2"},
+		{`(define (f x) x) (set! f car) (f 1 2)`, ""},
+		{`(switch (x) 1 'a 2 'b 1 'c 'd)`, "1:10: Unbound variable x\n1:23: Switch clause 1 can never match"},
+		{`(switch (2) 1 'a 2 'b)`, "1:13: Switch clause 1 can never match"},
+		{`(switch () #f 'a (= 1 1) 'b #t 'c 'd)`, "1:12: Switch clause #f can never match\n1:35: Unreachable code"},
+		{`(lambda () (error "no") 1)`, "1:25: Unreachable code"},
+		{`(define (fail) (error "no")) (define (g) (fail) 'x)`, "1:49: Unreachable code"},
+		{`(if)`, "1:1: if expects 2 or 3 arguments, got 0"},
+		{`(define (f) (g))`, "1:14: Unbound variable g"},
+		{`(define (f) (g)) (define (g) 1)`, ""},
+		{`(map car)`, "1:1: map expects at least 2 arguments, got 1"},
+		{`(list (abs -1) (range 0 2))`, ""},
+	}
+	for _, tst := range tests {
+		var got []string
+		for _, e := range Vet([]byte(tst.test)) {
+			got = append(got, e.Error())
+		}
+		if strings.Join(got, "\n") != tst.expected {
+			t.Errorf("For test string %s\nExpected:\n%s\nGot:\n%s", tst.test, tst.expected, strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestVetWarnings(t *testing.T) {
+	src := "(define (f) (g))\n(let ((a 1)) (car 1 2))\n(h)"
+	expected := `warning 1:14: Unbound variable g
+warning 2:8: a is bound but never used
+warning 2:14: car expects 1 arguments, got 2
+error 3:2: Unbound variable h`
+	var got []string
+	for _, e := range Vet([]byte(src)) {
+		sev := "error"
+		if e.Warning {
+			sev = "warning"
+		}
+		got = append(got, sev+" "+e.Error())
+	}
+	if s := strings.Join(got, "\n"); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+}
+
+// usageArity returns the number of arguments the first line of doc, such
+// as (f x [y] z ...), shows a procedure takes. An argument in brackets is
+// optional and one followed by ... may be repeated or left out.
+func usageArity(doc string) [2]int {
+	usage := strings.TrimSuffix(strings.TrimPrefix(strings.SplitN(doc, "\n", 2)[0], "("), ")")
+	min, max := 0, 0
+	optional, inBrackets := false, false
+	for _, f := range strings.Fields(usage)[1:] {
+		switch {
+		case f == "..." || f == "...]":
+			if !optional {
+				min--
+			}
+			max = -1
+		case strings.HasPrefix(f, "["):
+			inBrackets = true
+			fallthrough
+		default:
+			optional = inBrackets
+			if !optional {
+				min++
+			}
+			if max >= 0 {
+				max++
+			}
+		}
+		if strings.HasSuffix(f, "]") {
+			inBrackets = false
+		}
+	}
+	return [2]int{min, max}
+}
+
+func TestUsageArity(t *testing.T) {
+	tests := []struct {
+		usage    string
+		min, max int
+	}{
+		{"(f)", 0, 0},
+		{"(f x y)", 2, 2},
+		{"(f x [y])", 1, 2},
+		{"(f x ...)", 0, -1},
+		{"(f x y ...)", 1, -1},
+		{"(f x [y ...])", 1, -1},
+		{"(f list ... [tail])", 0, -1},
+	}
+	for _, tst := range tests {
+		if a := usageArity(tst.usage + "\nDoes something."); a != [2]int{tst.min, tst.max} {
+			t.Errorf("For %s expected %d %d, got %v", tst.usage, tst.min, tst.max, a)
+		}
+	}
+}
+
+func TestArities(t *testing.T) {
+	// Calling a builtin with too few or too many arguments must fail as
+	// its Min and Max say, so that vet reports the calls evaluation would,
+	// and its usage must show them
+	in := New(&Options{Stdout: io.Discard, Stderr: io.Discard, Stdin: strings.NewReader("")})
+	for _, bs := range builtinTables {
+		for _, b := range bs {
+			a := [2]int{b.Min, b.Max}
+			if u := usageArity(b.Doc); u != a {
+				t.Errorf("%s takes %d to %d arguments, but its usage shows %d to %d", b.Name, a[0], a[1], u[0], u[1])
+			}
+			var counts []int
+			if a[0] > 0 {
+				counts = append(counts, a[0]-1)
+			}
+			if a[1] >= 0 {
+				counts = append(counts, a[1]+1)
+			}
+			for _, n := range counts {
+				_, err := b.Fn(in, make([]Value, n))
+				expected := checkArity(b.Name, n, a[0], a[1])
+				if fmt.Sprint(err) != fmt.Sprint(expected) {
+					t.Errorf("%s with %d arguments: expected %v, got %v", b.Name, n, expected, err)
+				}
+			}
+		}
+	}
+}
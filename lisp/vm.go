@@ -0,0 +1,237 @@
+package lisp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Closure is a procedure compiled for the bytecode VM.
+type Closure struct {
+	proto *proto
+	env   *frame
+	name  string
+}
+
+func (cl *Closure) String() string {
+	if cl.name == "" {
+		return "#<lambda>"
+	}
+	return "#<lambda " + cl.name + ">"
+}
+
+func (cl *Closure) displayName() string {
+	if cl.name == "" {
+		return "lambda"
+	}
+	return cl.name
+}
+
+// bind returns a new frame with cl's parameters bound to args.
+func (cl *Closure) bind(args []Value) (*frame, error) {
+	vals, err := bindArgs(cl, cl.proto.lambda, args)
+	if err != nil {
+		return nil, err
+	}
+	return &frame{vals: vals, names: cl.proto.lambda.names, up: cl.env}, nil
+}
+
+// A callFrame is an active call of a proto.
+type callFrame struct {
+	cl   *Closure // nil for a top level expression
+	p    *proto
+	pc   int
+	env  *frame
+	base int // Index in the stack of the called procedure
+	// The position of the call, or zero when called from Go
+	row, col int
+}
+
+// run evaluates a compiled top level expression.
+func (in *Interpreter) run(p *proto) (Value, error) {
+	return in.exec(callFrame{p: p}, nil)
+}
+
+// callClosure calls cl from Go.
+func (in *Interpreter) callClosure(cl *Closure, args []Value) (Value, error) {
+	env, err := cl.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	if in.depth >= in.maxDepth {
+		return nil, fmt.Errorf("Maximum evaluation depth %d exceeded", in.maxDepth)
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+	return in.exec(callFrame{cl: cl, p: cl.proto, env: env}, []Value{cl})
+}
+
+// exec runs fr until it returns. Calls between closures push frames onto
+// a slice rather than recursing, so only calls made from Go builtins use
+// the Go stack.
+func (in *Interpreter) exec(fr callFrame, stack []Value) (Value, error) {
+	frames := []callFrame{fr}
+	depth := in.depth
+	defer func() { in.depth = depth }()
+	f := &frames[0]
+	for {
+		ip := f.pc
+		op := opcode(f.p.code[ip])
+		f.pc++
+		switch op {
+		case opConst:
+			f.pc += 2
+			stack = append(stack, f.p.consts[operand(f.p.code, ip, 0)])
+		case opLocal:
+			f.pc += 4
+			fr := f.env
+			for d := operand(f.p.code, ip, 0); d > 0; d-- {
+				fr = fr.up
+			}
+			v := fr.vals[operand(f.p.code, ip, 1)]
+			if _, ok := v.(unset); ok {
+				return nil, in.vmError(frames, ip, fmt.Errorf("Unbound variable %s", fr.names[operand(f.p.code, ip, 1)]))
+			}
+			stack = append(stack, v)
+		case opSetLocal:
+			f.pc += 4
+			fr := f.env
+			for d := operand(f.p.code, ip, 0); d > 0; d-- {
+				fr = fr.up
+			}
+			if _, ok := fr.vals[operand(f.p.code, ip, 1)].(unset); ok {
+				return nil, in.vmError(frames, ip, fmt.Errorf("Unbound variable %s", fr.names[operand(f.p.code, ip, 1)]))
+			}
+			fr.vals[operand(f.p.code, ip, 1)] = stack[len(stack)-1]
+		case opDefLocal:
+			f.pc += 2
+			sym := f.env.names[operand(f.p.code, ip, 0)]
+			f.env.vals[operand(f.p.code, ip, 0)] = nameProcedure(stack[len(stack)-1], sym.Name)
+			stack[len(stack)-1] = sym
+		case opGlobal:
+			f.pc += 2
+			g := f.p.consts[operand(f.p.code, ip, 0)].(*globalNode)
+			v, ok := g.env[g.sym]
+			if !ok {
+				return nil, in.vmError(frames, ip, fmt.Errorf("Unbound variable %s", g.sym))
+			}
+			stack = append(stack, v)
+		case opSetGlobal:
+			f.pc += 2
+			g := f.p.consts[operand(f.p.code, ip, 0)].(*globalNode)
+			if _, ok := g.env[g.sym]; !ok {
+				return nil, in.vmError(frames, ip, fmt.Errorf("Unbound variable %s", g.sym))
+			}
+			g.env[g.sym] = stack[len(stack)-1]
+		case opDefGlobal:
+			f.pc += 2
+			g := f.p.consts[operand(f.p.code, ip, 0)].(*globalNode)
+			g.env[g.sym] = nameProcedure(stack[len(stack)-1], g.sym.Name)
+			stack[len(stack)-1] = g.sym
+		case opPop:
+			stack = stack[:len(stack)-1]
+		case opDup:
+			stack = append(stack, stack[len(stack)-1])
+		case opEqual:
+			n := len(stack)
+			stack = append(stack[:n-2], equal(stack[n-2], stack[n-1]))
+		case opJump:
+			f.pc = operand(f.p.code, ip, 0)
+		case opJumpIfFalse:
+			f.pc += 2
+			if !truthy(stack[len(stack)-1]) {
+				f.pc = operand(f.p.code, ip, 0)
+			}
+			stack = stack[:len(stack)-1]
+		case opClosure:
+			f.pc += 2
+			p := f.p.consts[operand(f.p.code, ip, 0)].(*proto)
+			stack = append(stack, &Closure{proto: p, env: f.env, name: p.lambda.name})
+		case opCall, opTailCall:
+			f.pc += 2
+			argc := operand(f.p.code, ip, 0)
+			base := len(stack) - argc - 1
+			cl, ok := stack[base].(*Closure)
+			if !ok {
+				args := make([]Value, argc)
+				copy(args, stack[base+1:])
+				v, err := in.apply(stack[base], args)
+				if err != nil {
+					return nil, in.vmError(frames, ip, err)
+				}
+				stack = append(stack[:base], v)
+				if op == opTailCall {
+					op = opReturn
+					break
+				}
+				continue
+			}
+			env, err := cl.bind(stack[base+1:])
+			if err != nil {
+				return nil, in.vmError(frames, ip, err)
+			}
+			row, col := f.p.position(ip)
+			if op == opTailCall {
+				stack = append(stack[:f.base], cl)
+				f.cl, f.p, f.pc, f.env, f.row, f.col = cl, cl.proto, 0, env, row, col
+				continue
+			}
+			if in.depth >= in.maxDepth {
+				return nil, in.vmError(frames, ip, fmt.Errorf("Maximum evaluation depth %d exceeded", in.maxDepth))
+			}
+			in.depth++
+			stack = stack[:base+1]
+			frames = append(frames, callFrame{cl: cl, p: cl.proto, env: env, base: base, row: row, col: col})
+			f = &frames[len(frames)-1]
+		case opPushEnv:
+			f.pc += 4
+			n, names := operand(f.p.code, ip, 0), f.p.consts[operand(f.p.code, ip, 1)].([]*Symbol)
+			vals := make([]Value, len(names))
+			copy(vals, stack[len(stack)-n:])
+			for i := n; i < len(vals); i++ {
+				vals[i] = unset{}
+			}
+			stack = stack[:len(stack)-n]
+			f.env = &frame{vals: vals, names: names, up: f.env}
+		case opPopEnv:
+			f.env = f.env.up
+		}
+		if op == opReturn {
+			v := stack[len(stack)-1]
+			if len(frames) == 1 {
+				return v, nil
+			}
+			stack = append(stack[:f.base], v)
+			frames = frames[:len(frames)-1]
+			f = &frames[len(frames)-1]
+			in.depth--
+		}
+	}
+}
+
+// operand returns the i'th operand of the instruction at ip.
+func operand(code []byte, ip, i int) int {
+	return int(binary.BigEndian.Uint16(code[ip+1+2*i:]))
+}
+
+// vmError positions err at the instruction at ip of the innermost frame,
+// unless it already has a position, and adds the active calls to its
+// trace.
+func (in *Interpreter) vmError(frames []callFrame, ip int, err error) error {
+	if l, ok := err.(ErrorList); ok {
+		return l
+	}
+	le, ok := err.(*Error)
+	if !ok {
+		row, col := frames[len(frames)-1].p.position(ip)
+		le = &Error{Row: row, Col: col, Msg: err.Error()}
+	}
+	if n := frames[len(frames)-1].p.lambda; n != nil {
+		setFile(le, n.file)
+	}
+	for i := len(frames) - 1; i >= 0; i-- {
+		if f := frames[i]; f.cl != nil && f.row != 0 {
+			addCall(le, f.cl.displayName(), f.row, f.col)
+		}
+	}
+	return le
+}
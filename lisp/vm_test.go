@@ -0,0 +1,60 @@
+package lisp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const fibSrc = `(define (fib n) (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))`
+
+const loopSrc = `(define (loop n acc)
+  (let ((m (- n 1)))
+    (switch (n) 0 acc (loop m (+ acc n)))))`
+
+func benchmarkEval(b *testing.B, eng Engine, def, call string) {
+	in := New(&Options{Stdout: io.Discard, Engine: eng})
+	if _, err := in.EvalString(def); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := in.EvalString(call); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFibTreeWalker(b *testing.B) { benchmarkEval(b, TreeWalker, fibSrc, "(fib 20)") }
+func BenchmarkFibBytecodeVM(b *testing.B) { benchmarkEval(b, BytecodeVM, fibSrc, "(fib 20)") }
+
+func BenchmarkLoopTreeWalker(b *testing.B) { benchmarkEval(b, TreeWalker, loopSrc, "(loop 10000 0)") }
+func BenchmarkLoopBytecodeVM(b *testing.B) { benchmarkEval(b, BytecodeVM, loopSrc, "(loop 10000 0)") }
+
+func TestCompileLimits(t *testing.T) {
+	var params []string
+	for i := 0; i < 70000; i++ {
+		params = append(params, fmt.Sprint("p", i))
+	}
+	ones := strings.Repeat(" 1", 22000)
+	tests := []struct {
+		test     string
+		expected string // The result of the tree walker
+	}{
+		// A local variable beyond the 16 bit operand
+		{"(define (f " + strings.Join(params, " ") + ") p69999) 'f", "f"},
+		// A jump beyond it
+		{"(if (car '(#f)) (do" + ones + ") 2)", "2"},
+	}
+	for _, tst := range tests {
+		v, err := New(&Options{Engine: TreeWalker}).EvalString(tst.test)
+		if err != nil || String(v) != tst.expected {
+			t.Errorf("Tree walker expected %s, got %s %v", tst.expected, String(v), err)
+		}
+		_, err = New(&Options{Engine: BytecodeVM}).EvalString(tst.test)
+		if err == nil || !strings.HasSuffix(err.Error(), "Expression too large to compile") {
+			t.Errorf("Expected the bytecode VM to fail to compile, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"gortloveslinux/lisp/lisp"
+)
+
+// runLSP serves the Language Server Protocol on standard input and output
+// until the client exits, and returns the process exit status.
+func runLSP(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: lisp lsp")
+		return 2
+	}
+	s := &lspServer{r: bufio.NewReader(os.Stdin), w: os.Stdout, docs: map[string]string{}}
+	if err := s.serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "lisp lsp:", err)
+		return 1
+	}
+	if !s.shutdown {
+		return 1
+	}
+	return 0
+}
+
+type lspServer struct {
+	r        *bufio.Reader
+	w        io.Writer
+	docs     map[string]string // The text of each open document by URI
+	shutdown bool
+}
+
+type lspMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// lspAt is the parameters of the requests about a position in a document.
+type lspAt struct {
+	TextDocument lspDocument `json:"textDocument"`
+	Position     lspPosition `json:"position"`
+}
+
+// The LSP's symbol and completion kinds used here
+const (
+	lspFunctionSymbol   = 12
+	lspVariableSymbol   = 13
+	lspFunctionItem     = 3
+	lspVariableItem     = 6
+	lspKeywordItem      = 14
+	lspErrorSeverity    = 1
+	lspWarningSeverity  = 2
+	lspMethodNotFound   = -32601
+	lspInvalidParams    = -32602
+	lspFullTextDocument = 1
+)
+
+// serve handles messages until the client sends exit or closes its end.
+func (s *lspServer) serve() error {
+	for {
+		m, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		result, lerr := s.handle(m)
+		if m.ID == nil {
+			continue
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": m.ID}
+		if lerr != nil {
+			resp["error"] = lerr
+		} else {
+			resp["result"] = result
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// read reads the next message, framed by a Content-Length header.
+func (s *lspServer) read() (*lspMessage, error) {
+	n := -1
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if v := strings.TrimPrefix(line, "Content-Length:"); v != line {
+			if n, err = strconv.Atoi(strings.TrimSpace(v)); err != nil {
+				return nil, fmt.Errorf("invalid header %q", line)
+			}
+		}
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return nil, err
+	}
+	m := &lspMessage{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *lspServer) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	return err
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	return s.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// handle handles the request or notification m, returning the result of a
+// request.
+func (s *lspServer) handle(m *lspMessage) (interface{}, *lspError) {
+	var p struct {
+		lspAt
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if len(m.Params) > 0 {
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+	}
+	uri := p.TextDocument.URI
+	text := s.docs[uri]
+	switch m.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       lspFullTextDocument,
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "lisp"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = p.TextDocument.Text
+		s.diagnose(uri)
+	case "textDocument/didChange":
+		// The whole text is sent on each change
+		if n := len(p.ContentChanges); n > 0 {
+			s.docs[uri] = p.ContentChanges[n-1].Text
+		}
+		s.diagnose(uri)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": []interface{}{}})
+	case "textDocument/hover":
+		row, col := fromLSP(text, p.Position)
+		h, ok := lisp.Hover([]byte(text), row, col)
+		if !ok {
+			return nil, nil
+		}
+		// The first line, and any more calls after it, show how it is called
+		lines := strings.Split(h, "\n")
+		n := 1
+		for n < len(lines) && strings.HasPrefix(lines[n], "(") {
+			n++
+		}
+		usage, desc := strings.Join(lines[:n], "\n"), strings.Join(lines[n:], "\n")
+		md := "```lisp\n" + usage + "\n```"
+		if desc != "" {
+			md += "\n" + desc
+		}
+		return map[string]interface{}{"contents": map[string]string{"kind": "markdown", "value": md}}, nil
+	case "textDocument/definition":
+		row, col := fromLSP(text, p.Position)
+		dr, dc, ok := lisp.DefinitionAt([]byte(text), row, col)
+		if !ok {
+			return nil, nil
+		}
+		return lspLocation{uri, wordRange(text, dr, dc)}, nil
+	case "textDocument/documentSymbol":
+		syms := []interface{}{}
+		for _, d := range lisp.Definitions([]byte(text)) {
+			kind := lspVariableSymbol
+			if d.Signature != "" {
+				kind = lspFunctionSymbol
+			}
+			syms = append(syms, map[string]interface{}{
+				"name":           d.Name,
+				"detail":         d.Signature,
+				"kind":           kind,
+				"range":          lspRange{toLSP(text, d.StartRow, d.StartCol), toLSP(text, d.EndRow, d.EndCol)},
+				"selectionRange": wordRange(text, d.Row, d.Col),
+			})
+		}
+		return syms, nil
+	case "textDocument/completion":
+		row, col := fromLSP(text, p.Position)
+		items := []interface{}{}
+		for i, c := range lisp.Completions([]byte(text), row, col) {
+			kind := lspVariableItem
+			switch c.Kind {
+			case lisp.ProcedureCompletion:
+				kind = lspFunctionItem
+			case lisp.SpecialFormCompletion:
+				kind = lspKeywordItem
+			}
+			items = append(items, map[string]interface{}{
+				"label":    c.Name,
+				"kind":     kind,
+				"detail":   c.Detail,
+				"sortText": fmt.Sprintf("%04d", i),
+			})
+		}
+		return items, nil
+	default:
+		if m.ID != nil {
+			return nil, &lspError{lspMethodNotFound, "method not found: " + m.Method}
+		}
+	}
+	return nil, nil
+}
+
+// diagnose publishes the problems lisp vet finds in the document uri.
+// Those that would stop it running are errors, the rest warnings.
+func (s *lspServer) diagnose(uri string) {
+	text := s.docs[uri]
+	diags := []interface{}{}
+	for _, e := range lisp.Vet([]byte(text)) {
+		sev := lspErrorSeverity
+		if e.Warning {
+			sev = lspWarningSeverity
+		}
+		row, col, msg := e.Row, e.Col, e.Msg
+		if e.File != "" {
+			// A problem in a file the document loads is shown at its start
+			row, col, msg = 1, 1, fmt.Sprintf("%s:%d:%d: %s", e.File, e.Row, e.Col, e.Msg)
+		}
+		diags = append(diags, map[string]interface{}{
+			"range":    wordRange(text, row, col),
+			"severity": sev,
+			"source":   "lisp",
+			"message":  msg,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diags})
+}
+
+// toLSP converts the 1-based row and rune column of a position in text to
+// the LSP's 0-based line and UTF-16 character.
+func toLSP(text string, row, col int) lspPosition {
+	lines := strings.Split(text, "\n")
+	if row < 1 {
+		return lspPosition{}
+	}
+	if row > len(lines) {
+		return lspPosition{Line: row - 1}
+	}
+	line := []rune(lines[row-1])
+	if col < 1 {
+		col = 1
+	}
+	if col-1 > len(line) {
+		col = len(line) + 1
+	}
+	return lspPosition{row - 1, len(utf16.Encode(line[:col-1]))}
+}
+
+// fromLSP converts an LSP position in text to a row and rune column.
+func fromLSP(text string, p lspPosition) (row, col int) {
+	lines := strings.Split(text, "\n")
+	if p.Line < 0 {
+		return 1, 1
+	}
+	if p.Line >= len(lines) {
+		return p.Line + 1, 1
+	}
+	n := 0
+	for i, r := range []rune(lines[p.Line]) {
+		if n >= p.Character {
+			return p.Line + 1, i + 1
+		}
+		n += utf16.RuneLen(r)
+	}
+	return p.Line + 1, len([]rune(lines[p.Line])) + 1
+}
+
+// wordRange returns the range of the atom at row, col of text, or of the
+// single rune there.
+func wordRange(text string, row, col int) lspRange {
+	if col < 1 {
+		col = 1
+	}
+	start := toLSP(text, row, col)
+	lines := strings.Split(text, "\n")
+	end := col + 1
+	if row >= 1 && row <= len(lines) {
+		line := []rune(lines[row-1])
+		for end = col; end <= len(line); end++ {
+			r := line[end-1]
+			if unicode.IsSpace(r) || strings.ContainsRune("()'\";", r) {
+				break
+			}
+		}
+		if end == col {
+			end++
+		}
+	}
+	return lspRange{start, toLSP(text, row, end)}
+}
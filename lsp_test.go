@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLSPPositions(t *testing.T) {
+	// 😀 is two UTF-16 code units, λ one
+	text := "a😀b\nλx\n"
+	tests := []struct {
+		row, col int
+		pos      lspPosition
+	}{
+		{1, 1, lspPosition{0, 0}},
+		{1, 2, lspPosition{0, 1}},
+		{1, 3, lspPosition{0, 3}},
+		{1, 4, lspPosition{0, 4}}, // Just past the end of the line
+		{2, 2, lspPosition{1, 1}},
+		{2, 3, lspPosition{1, 2}},
+		{3, 1, lspPosition{2, 0}}, // The empty last line
+	}
+	for _, tst := range tests {
+		if got := toLSP(text, tst.row, tst.col); got != tst.pos {
+			t.Errorf("toLSP %d:%d: expected %v, got %v", tst.row, tst.col, tst.pos, got)
+		}
+		if row, col := fromLSP(text, tst.pos); row != tst.row || col != tst.col {
+			t.Errorf("fromLSP %v: expected %d:%d, got %d:%d", tst.pos, tst.row, tst.col, row, col)
+		}
+	}
+	// Positions beyond the text are clamped
+	clamped := []struct {
+		row, col int
+		pos      lspPosition
+	}{
+		{1, 9, lspPosition{0, 4}},
+		{2, 0, lspPosition{1, 0}},
+		{7, 3, lspPosition{6, 0}},
+		{0, 1, lspPosition{0, 0}},
+	}
+	for _, tst := range clamped {
+		if got := toLSP(text, tst.row, tst.col); got != tst.pos {
+			t.Errorf("toLSP %d:%d: expected %v, got %v", tst.row, tst.col, tst.pos, got)
+		}
+	}
+	froms := []struct {
+		pos      lspPosition
+		row, col int
+	}{
+		{lspPosition{0, 2}, 1, 3}, // Within the surrogate pair of 😀
+		{lspPosition{0, 40}, 1, 4},
+		{lspPosition{1, 9}, 2, 3},
+		{lspPosition{9, 4}, 10, 1},
+		{lspPosition{-1, 0}, 1, 1},
+	}
+	for _, tst := range froms {
+		if row, col := fromLSP(text, tst.pos); row != tst.row || col != tst.col {
+			t.Errorf("fromLSP %v: expected %d:%d, got %d:%d", tst.pos, tst.row, tst.col, row, col)
+		}
+	}
+}
+
+func TestWordRange(t *testing.T) {
+	text := "(f 😀x y)\n"
+	tests := []struct {
+		row, col int
+		r        lspRange
+	}{
+		{1, 2, lspRange{lspPosition{0, 1}, lspPosition{0, 2}}},
+		{1, 4, lspRange{lspPosition{0, 3}, lspPosition{0, 6}}},
+		{1, 1, lspRange{lspPosition{0, 0}, lspPosition{0, 1}}}, // A parenthesis is a range of its own
+		{1, 0, lspRange{lspPosition{0, 0}, lspPosition{0, 1}}},
+		{1, 20, lspRange{lspPosition{0, 9}, lspPosition{0, 9}}},
+	}
+	for _, tst := range tests {
+		if got := wordRange(text, tst.row, tst.col); got != tst.r {
+			t.Errorf("wordRange %d:%d: expected %v, got %v", tst.row, tst.col, tst.r, got)
+		}
+	}
+}
+
+// frame returns the message v framed as the LSP sends it.
+func frame(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+// readFrames returns the messages framed in out.
+func readFrames(t *testing.T, out []byte) []map[string]interface{} {
+	r := bufio.NewReader(bytes.NewReader(out))
+	var ms []map[string]interface{}
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			return ms
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+		if err != nil {
+			t.Fatalf("Bad header %q", header)
+		}
+		if blank, _ := r.ReadString('\n'); blank != "\r\n" {
+			t.Fatalf("Expected a blank line after the header, got %q", blank)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("Message %q: %v", b, err)
+		}
+		ms = append(ms, m)
+	}
+}
+
+// lspRoundTrip serves the messages in, returning those sent back.
+func lspRoundTrip(t *testing.T, in ...interface{}) []map[string]interface{} {
+	var src strings.Builder
+	for _, m := range in {
+		src.WriteString(frame(t, m))
+	}
+	var out bytes.Buffer
+	s := &lspServer{r: bufio.NewReader(strings.NewReader(src.String())), w: &out, docs: map[string]string{}}
+	if err := s.serve(); err != nil {
+		t.Fatal(err)
+	}
+	return readFrames(t, out.Bytes())
+}
+
+// get returns the value at the path of keys and indexes within v.
+func get(v interface{}, path ...interface{}) interface{} {
+	for _, k := range path {
+		switch k := k.(type) {
+		case string:
+			m, _ := v.(map[string]interface{})
+			v = m[k]
+		case int:
+			l, _ := v.([]interface{})
+			if k >= len(l) {
+				return nil
+			}
+			v = l[k]
+		}
+	}
+	return v
+}
+
+func TestLSPServe(t *testing.T) {
+	const uri = "file:///test.lisp"
+	// The unbound variable follows a character outside the BMP
+	text := "(define (f x) (car x))\n(list \"😀\" undefined-x)\n"
+	ms := lspRoundTrip(t,
+		map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "initialized", "params": map[string]interface{}{}},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": "lisp", "version": 1, "text": text}}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 2, "method": "textDocument/hover", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri}, "position": map[string]interface{}{"line": 0, "character": 16}}},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "no/such/method"},
+		map[string]interface{}{"jsonrpc": "2.0", "id": 4, "method": "shutdown"},
+		map[string]interface{}{"jsonrpc": "2.0", "method": "exit"},
+	)
+	if len(ms) != 5 {
+		t.Fatalf("Expected 5 messages, got %d: %v", len(ms), ms)
+	}
+	if get(ms[0], "id") != 1.0 || get(ms[0], "result", "capabilities", "hoverProvider") != true {
+		t.Errorf("Unexpected initialize response %v", ms[0])
+	}
+	if get(ms[1], "method") != "textDocument/publishDiagnostics" || get(ms[1], "params", "uri") != uri {
+		t.Fatalf("Expected diagnostics, got %v", ms[1])
+	}
+	diag := get(ms[1], "params", "diagnostics", 0)
+	want := map[string]interface{}{"line": 1.0, "character": 11.0}
+	if !reflect.DeepEqual(get(diag, "range", "start"), want) || get(diag, "message") != "Unbound variable undefined-x" {
+		t.Errorf("Expected Unbound variable undefined-x at %v, got %v", want, diag)
+	}
+	want = map[string]interface{}{"line": 1.0, "character": 22.0}
+	if !reflect.DeepEqual(get(diag, "range", "end"), want) {
+		t.Errorf("Expected the diagnostic to end at %v, got %v", want, get(diag, "range", "end"))
+	}
+	if get(ms[2], "id") != 2.0 || !strings.Contains(fmt.Sprint(get(ms[2], "result", "contents", "value")), "(car pair)") {
+		t.Errorf("Unexpected hover response %v", ms[2])
+	}
+	if get(ms[3], "id") != 3.0 || get(ms[3], "error", "code") != float64(lspMethodNotFound) {
+		t.Errorf("Expected method not found, got %v", ms[3])
+	}
+	if get(ms[4], "id") != 4.0 || get(ms[4], "error") != nil {
+		t.Errorf("Unexpected shutdown response %v", ms[4])
+	}
+}
+
+func TestLSPLoadedFileDiagnostic(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.lisp"), []byte("(define x\n  (car"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	const uri = "file:///main.lisp"
+	ms := lspRoundTrip(t, map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "text": "\n  (load \"bad\")\n"}}})
+	diag := get(ms[0], "params", "diagnostics", 0)
+	// The problem is in bad.lisp, so it is shown at the start of the document
+	want := map[string]interface{}{"line": 0.0, "character": 0.0}
+	if !reflect.DeepEqual(get(diag, "range", "start"), want) || get(diag, "message") != "bad.lisp:2:7: Expecting ')' encountered EOF" {
+		t.Errorf("Unexpected diagnostic %v", diag)
+	}
+}
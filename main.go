@@ -1,15 +1,161 @@
 package main
 
-import "fmt"
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
-const prompt = "> "
+	"gortloveslinux/lisp/lisp"
+)
+
+const (
+	prompt     = "> "
+	contPrompt = "  "
+)
 
 func main() {
-	for {
-		repl()
+	os.Exit(run(os.Args[1:]))
+}
+
+// run evaluates the program named by args, or starts the REPL when there is
+// none, and returns the process exit status. "lisp fmt", "lisp vet",
+// "lisp lsp" and "lisp highlight" run the formatter, the checker, the
+// language server and the HTML highlighter.
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "fmt":
+			return runFmt(args[1:])
+		case "vet":
+			return runVet(args[1:])
+		case "lsp":
+			return runLSP(args[1:])
+		case "highlight":
+			return runHighlight(args[1:])
+		}
+	}
+	fs := flag.NewFlagSet("lisp", flag.ContinueOnError)
+	expr := fs.String("e", "", "evaluate `expr` instead of a file")
+	vm := fs.Bool("vm", false, "run on the bytecode VM instead of the tree walker")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: lisp [-vm] [-e expr | file.lisp | -] [args ...]")
+		fmt.Fprintln(fs.Output(), "       lisp fmt [-w] [-d] [file.lisp ...]")
+		fmt.Fprintln(fs.Output(), "       lisp vet [file.lisp ...]")
+		fmt.Fprintln(fs.Output(), "       lisp lsp")
+		fmt.Fprintln(fs.Output(), "       lisp highlight [-fragment] [file.lisp]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	// The REPL and the program read standard input through one buffer
+	stdin := bufio.NewReader(os.Stdin)
+	opts := &lisp.Options{Stdin: stdin}
+	if *vm {
+		opts.Engine = lisp.BytecodeVM
+	}
+	in := lisp.New(opts)
+	rest := fs.Args()
+	var (
+		src  io.Reader
+		name string
+	)
+	switch {
+	case *expr != "":
+		src, name = strings.NewReader(*expr), "-e"
+	case len(rest) == 0:
+		repl(in, opts, stdin)
+		return 0
+	case rest[0] == "-":
+		src, name, rest = stdin, "<stdin>", rest[1:]
+	default:
+		// A file is evaluated by EvalFile so that it loads files relative
+		// to its own directory
+		if _, err := os.Stat(rest[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		name, rest = rest[0], rest[1:]
+	}
+	var argv []lisp.Value
+	for _, a := range rest {
+		argv = append(argv, a)
+	}
+	in.Define("*args*", lisp.List(argv...))
+	var err error
+	if src == nil {
+		_, err = in.EvalFile(name)
+	} else {
+		_, err = in.Eval(src)
 	}
+	if err != nil {
+		report(name, err)
+		return 1
+	}
+	return 0
+}
+
+// report writes err to standard error, each error on its own line
+// prefixed by the name of the input unless it names the file it is in.
+func report(name string, err error) {
+	if l, ok := err.(lisp.ErrorList); ok {
+		for _, e := range l {
+			report(name, e)
+		}
+		return
+	}
+	if e, ok := err.(*lisp.Error); ok && e.File != "" {
+		fmt.Fprintln(os.Stderr, e)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s:%s\n", name, err)
 }
 
-func repl() {
-	fmt.Print(prompt)
+// repl reads expressions from standard input through stdin, evaluating
+// each as soon as it is complete and printing its value. On a terminal
+// lines are edited with history and completion of the names bound in the
+// interpreter. A line starting with a comma is a command such as ,doc or
+// ,quit.
+func repl(in *lisp.Interpreter, opts *lisp.Options, stdin *bufio.Reader) {
+	s := newReplSession(in, opts)
+	s.run(newLineEditor(os.Stdin, stdin, os.Stdout, historyFile(), s.complete))
+}
+
+// run reads and evaluates the lines ed reads until the input ends or the
+// session is left with ,quit.
+func (s *replSession) run(ed *lineEditor) {
+	var src strings.Builder
+	p := prompt
+	for {
+		line, err := ed.readLine(p)
+		if err == errInterrupt {
+			src.Reset()
+			p = prompt
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if src.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ",") {
+			if !s.command(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+		src.WriteString(line)
+		src.WriteByte('\n')
+		v, err := s.in.EvalString(src.String())
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			p = contPrompt
+			continue
+		}
+		s.print(v, err)
+		src.Reset()
+		p = prompt
+	}
+	fmt.Println()
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gortloveslinux/lisp/lisp"
+)
+
+// A replSession is the environment of the REPL, which ,reset replaces.
+type replSession struct {
+	in   *lisp.Interpreter
+	opts *lisp.Options
+	// base holds the bindings a new interpreter starts with, which ,env
+	// leaves out unless they are redefined
+	base map[string]lisp.Value
+}
+
+// results are the variables holding the most recent results, latest first.
+var results = []string{"*1", "*2", "*3"}
+
+// replCommands are the commands the REPL takes after a comma.
+var replCommands = []struct{ name, args, help string }{
+	{"doc", "name", "show the documentation of a special form or procedure"},
+	{"env", "", "list the variables defined in this session"},
+	{"time", "expr", "evaluate expr and show how long it took"},
+	{"expand", "expr", "show expr with its shorthand written out"},
+	{"load", "file", "evaluate the expressions of file"},
+	{"reset", "", "start again in a new environment"},
+	{"quit", "", "leave the REPL"},
+	{"help", "", "list these commands"},
+}
+
+// newReplSession returns a session evaluating in in, which must not have
+// evaluated anything yet, so that its bindings are those it starts with.
+func newReplSession(in *lisp.Interpreter, opts *lisp.Options) *replSession {
+	s := &replSession{in: in, opts: opts, base: map[string]lisp.Value{}}
+	for _, name := range in.Names() {
+		s.base[name], _ = in.Lookup(name)
+	}
+	for _, name := range results {
+		s.in.Define(name, nil)
+	}
+	return s
+}
+
+// defined returns the names of the variables defined in the session,
+// leaving out those it started with unless they have been redefined.
+func (s *replSession) defined() []string {
+	var names []string
+	for _, name := range s.in.Names() {
+		v, _ := s.in.Lookup(name)
+		if b, ok := s.base[name]; ok && b == v || isResult(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// print prints the value v or the error err, and makes v the most recent
+// result.
+func (s *replSession) print(v lisp.Value, err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Println(lisp.String(v))
+	for i := len(results) - 1; i > 0; i-- {
+		prev, _ := s.in.Lookup(results[i-1])
+		s.in.Define(results[i], prev)
+	}
+	s.in.Define(results[0], v)
+}
+
+// complete returns the bound names, or after a comma the commands, that
+// start with prefix.
+func (s *replSession) complete(prefix string) []string {
+	var names []string
+	if strings.HasPrefix(prefix, ",") {
+		for _, c := range replCommands {
+			if strings.HasPrefix(c.name, prefix[1:]) {
+				names = append(names, ","+c.name)
+			}
+		}
+		return names
+	}
+	for _, name := range s.in.Names() {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// command runs line, a command starting with a comma, and reports whether
+// the REPL should go on. A command may be abbreviated to any prefix that
+// is not ambiguous.
+func (s *replSession) command(line string) bool {
+	name, arg := strings.TrimPrefix(line, ","), ""
+	if i := strings.IndexFunc(name, func(r rune) bool { return r == ' ' || r == '\t' }); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i:])
+	}
+	var match []string
+	for _, c := range replCommands {
+		if c.name == name {
+			match = []string{c.name}
+			break
+		}
+		if name != "" && strings.HasPrefix(c.name, name) {
+			match = append(match, c.name)
+		}
+	}
+	if len(match) != 1 {
+		fmt.Fprintf(os.Stderr, "Unknown command ,%s; ,help lists the commands\n", name)
+		return true
+	}
+	switch match[0] {
+	case "doc":
+		if arg == "" {
+			fmt.Fprintln(os.Stderr, "usage: ,doc name")
+		} else if d, ok := s.in.Doc(arg); ok {
+			fmt.Println(d)
+		} else {
+			fmt.Fprintf(os.Stderr, "No documentation for %s\n", arg)
+		}
+	case "env":
+		for _, name := range s.defined() {
+			v, _ := s.in.Lookup(name)
+			fmt.Printf("%s = %s\n", name, lisp.String(v))
+		}
+	case "time":
+		start := time.Now()
+		v, err := s.in.EvalString(arg)
+		elapsed := time.Since(start)
+		s.print(v, err)
+		fmt.Printf(";; %v\n", elapsed)
+	case "expand":
+		vs, err := lisp.Expand(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		for _, v := range vs {
+			lisp.PrettyPrint(os.Stdout, v, termWidth(int(os.Stdout.Fd())))
+			fmt.Println()
+		}
+	case "load":
+		if arg == "" {
+			fmt.Fprintln(os.Stderr, "usage: ,load file")
+			break
+		}
+		v, err := s.in.EvalFile(arg)
+		if err != nil {
+			report(arg, err)
+			break
+		}
+		s.print(v, nil)
+	case "reset":
+		*s = *newReplSession(lisp.New(s.opts), s.opts)
+	case "quit":
+		return false
+	case "help":
+		for _, c := range replCommands {
+			fmt.Printf("  %-14s %s\n", strings.TrimSpace(","+c.name+" "+c.args), c.help)
+		}
+		fmt.Println("  *1 *2 *3 hold the last three results.")
+	}
+	return true
+}
+
+func isResult(name string) bool {
+	for _, r := range results {
+		if name == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gortloveslinux/lisp/lisp"
+)
+
+func TestReplEnv(t *testing.T) {
+	opts := &lisp.Options{Stdout: io.Discard}
+	s := newReplSession(lisp.New(opts), opts)
+	if names := s.defined(); names != nil {
+		t.Errorf("A new session defines %v", names)
+	}
+	if _, err := s.in.EvalString("(define x 1) (define (take n l) l) (define map map)"); err != nil {
+		t.Fatal(err)
+	}
+	if names := s.defined(); !reflect.DeepEqual(names, []string{"take", "x"}) {
+		t.Errorf("Expected take and x, got %v", names)
+	}
+	s.command(",reset")
+	if names := s.defined(); names != nil {
+		t.Errorf("A reset session defines %v", names)
+	}
+}
+
+func TestReplReadLine(t *testing.T) {
+	// The editor and read-line share the buffer, so neither takes the
+	// other's lines
+	r := bufio.NewReader(strings.NewReader("(define a (read-line))\nfirst line\n(define b (list (read) (read-line)))\n(x y) rest\n(define c 3)\n"))
+	opts := &lisp.Options{Stdin: r, Stdout: io.Discard}
+	s := newReplSession(lisp.New(opts), opts)
+	s.run(&lineEditor{r: r, w: io.Discard})
+	for name, want := range map[string]string{"a": `"first line"`, "b": `((x y) " rest")`, "c": "3"} {
+		if v, _ := s.in.Lookup(name); lisp.String(v) != want {
+			t.Errorf("Expected %s to be %s, got %s", name, want, lisp.String(v))
+		}
+	}
+}
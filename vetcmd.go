@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gortloveslinux/lisp/lisp"
+)
+
+// runVet reports likely mistakes in the files named by args, or standard
+// input when there are none, and returns the process exit status.
+func runVet(args []string) int {
+	fs := flag.NewFlagSet("lisp vet", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: lisp vet [file.lisp ...]")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	names := fs.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	status := 0
+	for _, name := range names {
+		var (
+			src []byte
+			err error
+		)
+		if name == "-" {
+			src, err = io.ReadAll(os.Stdin)
+			name = "<stdin>"
+		} else {
+			src, err = os.ReadFile(name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if errs := lisp.Vet(src); errs != nil {
+			report(name, errs)
+			status = 1
+		}
+	}
+	return status
+}